@@ -2,45 +2,160 @@ package main
 
 import (
 	"bufio"
+	"fmt"
+	"go/build"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
 
-func apipath(base string) string {
-	return filepath.Join(os.Getenv("GOROOT"), "api", base)
+// ApiFiles are the api files of the symbols added in a Go version.
+type ApiFiles struct {
+	Ver   string
+	Files []string
+}
+
+// FindApiFiles returns the api files of the GOROOT of the go command ordered
+// by version: go1.txt and except.txt, the go1.N.txt files and next/*.txt as
+// the version after the last release.
+func FindApiFiles() ([]*ApiFiles, error) {
+	out, err := exec.Command("go", "env", "GOROOT").Output()
+	if err != nil {
+		return nil, fmt.Errorf("go env GOROOT: %v", err)
+	}
+	dir := filepath.Join(strings.TrimSpace(string(out)), "api")
+	files, err := filepath.Glob(filepath.Join(dir, "go1*.txt"))
+	if err != nil {
+		return nil, err
+	}
+	var list []*ApiFiles
+	for _, file := range files {
+		ver := strings.TrimSuffix(filepath.Base(file), ".txt")
+		if apiVersion(ver) < 0 {
+			continue
+		}
+		list = append(list, &ApiFiles{Ver: ver, Files: []string{file}})
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("no api files in %v", dir)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return apiVersion(list[i].Ver) < apiVersion(list[j].Ver)
+	})
+	if except := filepath.Join(dir, "except.txt"); fileExists(except) {
+		list[0].Files = append(list[0].Files, except)
+	}
+	if next, _ := filepath.Glob(filepath.Join(dir, "next", "*.txt")); len(next) > 0 {
+		ver := fmt.Sprintf("go1.%v", apiVersion(list[len(list)-1].Ver)+1)
+		list = append(list, &ApiFiles{Ver: ver, Files: next})
+	}
+	return list, nil
+}
+
+func fileExists(filename string) bool {
+	_, err := os.Stat(filename)
+	return err == nil
 }
 
 //pkg syscall (windows-386), const CERT_E_CN_NO_MATCH = 2148204815
 var sym = regexp.MustCompile(`^pkg (\S+)\s?(.*)?, (?:(var|func|type|const)) ([A-Z]\w*)`)
 var num = regexp.MustCompile(`^\-?[0-9]+$`)
 
+//pkg net/http, method (*Request) Clone(context.Context) *Request
+var method = regexp.MustCompile(`^pkg (\S+)\s?(.*)?, method \(\*?(\w+)(?:\[.*?\])?\) ([A-Z]\w*)`)
+
+//pkg os, type FileInfo interface, IsDir() bool
+//pkg runtime, type BlockProfileRecord struct, embedded StackRecord
+var member = regexp.MustCompile(`^pkg (\S+)\s?(.*)?, type (\w+)(?:\[.*?\])? (struct|interface), (?:embedded \*?(?:\w+\.)?)?([A-Z]\w*)`)
+
+// apiVersion returns the minor version N of the Go version ver, go1 or go1.N,
+// or -1 if ver is not a Go version.
+func apiVersion(ver string) int {
+	if ver == "go1" {
+		return 0
+	}
+	if !strings.HasPrefix(ver, "go1.") {
+		return -1
+	}
+	n, err := strconv.Atoi(ver[4:])
+	if err != nil || n < 0 {
+		return -1
+	}
+	return n
+}
+
 type KeyType int
 
 const (
 	Normal      KeyType = 1
 	ConstInt64  KeyType = 2
 	ConstUnit64 KeyType = 3
+
+	// the members of types, keyed by pkg.Type.Name
+	Method          KeyType = 4
+	Field           KeyType = 5
+	InterfaceMethod KeyType = 6
 )
 
+// GoApi are the symbols added in the Go version Ver, the package-level
+// symbols keyed by pkg.Name and the methods, struct fields and interface
+// methods by pkg.Type.Name. Contexts are the contexts, as windows-386 or
+// linux-arm-cgo, of the keys only listed for some of them.
 type GoApi struct {
-	Keys map[string]KeyType
-	Ver  string
+	Keys     map[string]KeyType
+	Contexts map[string][]string
+	Pkgs     map[string]bool
+	Ver      string
+}
+
+// LoadApi loads the symbols of the api files of the version ver.
+func LoadApi(ver string, files ...string) (*GoApi, error) {
+	api := &GoApi{
+		Ver:      ver,
+		Keys:     make(map[string]KeyType),
+		Contexts: make(map[string][]string),
+		Pkgs:     make(map[string]bool),
+	}
+	for _, file := range files {
+		if err := api.loadFile(file); err != nil {
+			return nil, err
+		}
+	}
+	return api, nil
 }
 
-func LoadApi(ver string) (*GoApi, error) {
-	f, err := os.Open(apipath(ver + ".txt"))
+func (api *GoApi) loadFile(filename string) error {
+	f, err := os.Open(filename)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	defer f.Close()
 	sc := bufio.NewScanner(f)
-	keys := make(map[string]KeyType)
 	for sc.Scan() {
 		l := sc.Text()
-		has := func(v string) bool { return strings.Contains(l, v) }
-		if has("interface, ") || has(", method (") {
+		if m := method.FindStringSubmatch(l); m != nil {
+			// 1 pkgname
+			// 2 os-arch-cgo
+			// 3 receiver type
+			// 4 name
+			api.add(m[1], m[3]+"."+m[4], Method, m[2])
+			continue
+		}
+		if m := member.FindStringSubmatch(l); m != nil {
+			// 1 pkgname
+			// 2 os-arch-cgo
+			// 3 type
+			// 4 struct|interface
+			// 5 name
+			kind := Field
+			if m[4] == "interface" {
+				kind = InterfaceMethod
+			}
+			api.add(m[1], m[3]+"."+m[5], kind, m[2])
 			continue
 		}
 		if m := sym.FindStringSubmatch(l); m != nil {
@@ -49,10 +164,9 @@ func LoadApi(ver string) (*GoApi, error) {
 			// 3 var|func|type|const
 			// 4 name
 			key := m[1] + "." + m[4]
-			if _, ok := keys[key]; ok {
+			if !api.add(m[1], m[4], Normal, m[2]) {
 				continue
 			}
-			keys[key] = Normal
 			if m[3] == "const" {
 				if pos := strings.LastIndex(l, "="); pos != -1 {
 					value := strings.TrimSpace(l[pos+1:])
@@ -60,9 +174,9 @@ func LoadApi(ver string) (*GoApi, error) {
 						_, err := strconv.ParseInt(l[pos+2:], 10, 32)
 						if err != nil {
 							if value[0] == '-' {
-								keys[key] = ConstInt64
+								api.Keys[key] = ConstInt64
 							} else {
-								keys[key] = ConstUnit64
+								api.Keys[key] = ConstUnit64
 							}
 						}
 					}
@@ -70,36 +184,144 @@ func LoadApi(ver string) (*GoApi, error) {
 			}
 		}
 	}
-	return &GoApi{Ver: ver, Keys: keys}, nil
+	return sc.Err()
+}
+
+// add adds the symbol name of the package pkg listed for the context ctx,
+// (windows-386), or for all the contexts if ctx is empty, and reports whether
+// the symbol is new.
+func (api *GoApi) add(pkg string, name string, typ KeyType, ctx string) bool {
+	key := pkg + "." + name
+	api.Pkgs[pkg] = true
+	ctx = strings.Trim(ctx, "()")
+	if ctxs, ok := api.Contexts[key]; ctx == "" {
+		api.Contexts[key] = nil
+	} else if !ok || ctxs != nil {
+		api.Contexts[key] = append(ctxs, ctx)
+	}
+	if _, ok := api.Keys[key]; ok {
+		return false
+	}
+	api.Keys[key] = typ
+	return true
 }
 
 type ApiCheck struct {
-	Base map[string]KeyType
-	Apis []*GoApi
+	Base         map[string]KeyType
+	Apis         []*GoApi
+	Contexts     map[string][]string // contexts of all the versions
+	ContextNames map[string]bool     // contexts checked by the api files
+	Pkgs         map[string]bool
+
+	platformPkgs map[string]bool // packages with keys of some contexts only
 }
 
 func NewApiCheck() *ApiCheck {
 	ac := &ApiCheck{}
 	ac.Base = make(map[string]KeyType)
+	ac.Contexts = make(map[string][]string)
+	ac.ContextNames = make(map[string]bool)
+	ac.Pkgs = make(map[string]bool)
 	return ac
 }
 
-func (ac *ApiCheck) LoadBase(vers ...string) error {
-	for _, ver := range vers {
-		api, err := LoadApi(ver)
+// addContexts merges the contexts of the keys of api, a key listed for all
+// the contexts by a version is listed for all of them.
+func (ac *ApiCheck) addContexts(api *GoApi) {
+	for k, ctxs := range api.Contexts {
+		for _, ctx := range ctxs {
+			ac.ContextNames[ctx] = true
+		}
+		if old, ok := ac.Contexts[k]; !ok {
+			ac.Contexts[k] = ctxs
+		} else if old != nil {
+			if ctxs == nil {
+				ac.Contexts[k] = nil
+			} else {
+				ac.Contexts[k] = append(old, ctxs...)
+			}
+		}
+	}
+	for pkg := range api.Pkgs {
+		ac.Pkgs[pkg] = true
+	}
+}
+
+// Platforms returns the contexts listing the symbol name of the api files,
+// nil if it is listed for all of them, ok reports whether name is listed.
+func (ac *ApiCheck) Platforms(name string) (ctxs []string, ok bool) {
+	ctxs, ok = ac.Contexts[name]
+	return
+}
+
+// PlatformPkg reports whether the api files list symbols of the package pkg
+// for some of the contexts only.
+func (ac *ApiCheck) PlatformPkg(pkg string) bool {
+	if ac.platformPkgs == nil {
+		ac.platformPkgs = make(map[string]bool)
+		for k, ctxs := range ac.Contexts {
+			if ctxs != nil {
+				pos := strings.LastIndex(k, "/") + 1
+				ac.platformPkgs[k[:pos+strings.Index(k[pos:], ".")]] = true
+			}
+		}
+	}
+	return ac.platformPkgs[pkg]
+}
+
+// apiContext returns the context of the api files for the context c, as
+// linux-386-cgo, or the other cgo variant if they check only one, as
+// windows-386. ok reports whether they check the platform of c.
+func (ac *ApiCheck) apiContext(c *build.Context) (name string, ok bool) {
+	name = contextName(c)
+	if ac.ContextNames[name] {
+		return name, true
+	}
+	if c.CgoEnabled {
+		name = osArchName(c)
+	} else {
+		name = osArchName(c) + "-cgo"
+	}
+	return name, ac.ContextNames[name]
+}
+
+// OnContext reports whether the api files list the symbol name for the
+// context c. The symbols they don't list and the contexts they don't check
+// are not constrained.
+func (ac *ApiCheck) OnContext(name string, c *build.Context) bool {
+	ctxs, ok := ac.Contexts[name]
+	if !ok || ctxs == nil {
+		return true
+	}
+	ctx, ok := ac.apiContext(c)
+	if !ok {
+		return true
+	}
+	for _, v := range ctxs {
+		if v == ctx {
+			return true
+		}
+	}
+	return false
+}
+
+func (ac *ApiCheck) LoadBase(apis ...*ApiFiles) error {
+	for _, v := range apis {
+		api, err := LoadApi(v.Ver, v.Files...)
 		if err != nil {
 			return err
 		}
 		for k, v := range api.Keys {
 			ac.Base[k] = v
 		}
+		ac.addContexts(api)
 	}
 	return nil
 }
 
-func (ac *ApiCheck) LoadApi(vers ...string) error {
-	for _, ver := range vers {
-		api, err := LoadApi(ver)
+func (ac *ApiCheck) LoadApi(apis ...*ApiFiles) error {
+	for _, v := range apis {
+		api, err := LoadApi(v.Ver, v.Files...)
 		if err != nil {
 			return err
 		}
@@ -108,6 +330,7 @@ func (ac *ApiCheck) LoadApi(vers ...string) error {
 				delete(api.Keys, k)
 			}
 		}
+		ac.addContexts(api)
 		ac.Apis = append(ac.Apis, api)
 	}
 	return nil
@@ -122,6 +345,28 @@ func (ac *ApiCheck) FincApis(name string) (vers []string) {
 	return
 }
 
+// Since returns the first version of the apis adding name, or "" if name is
+// in the base or not in the apis.
+func (ac *ApiCheck) Since(name string) string {
+	if vers := ac.FincApis(name); len(vers) > 0 {
+		return vers[0]
+	}
+	return ""
+}
+
+// sortVers returns vers ordered as the apis.
+func (ac *ApiCheck) sortVers(vers []string) []string {
+	var list []string
+	for _, ver := range ac.ApiVers() {
+		for _, v := range vers {
+			if v == ver {
+				list = append(list, v)
+			}
+		}
+	}
+	return list
+}
+
 func (ac *ApiCheck) ApiVers() (vers []string) {
 	for _, api := range ac.Apis {
 		vers = append(vers, api.Ver)
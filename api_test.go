@@ -0,0 +1,134 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeApi writes the lines of an api file of the version ver to dir.
+func writeApi(t *testing.T, dir string, ver string, lines ...string) *ApiFiles {
+	filename := filepath.Join(dir, ver+".txt")
+	if err := os.WriteFile(filename, []byte(strings.Join(lines, "\n")+"\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	return &ApiFiles{Ver: ver, Files: []string{filename}}
+}
+
+func TestLoadApi(t *testing.T) {
+	tests := []struct {
+		line string
+		key  string
+		typ  KeyType
+		ctxs []string
+	}{
+		{"pkg strings, func Cut(string, string) (string, string, bool)", "strings.Cut", Normal, nil},
+		{"pkg syscall (windows-386), const CERT_E_CN_NO_MATCH = 2148204815", "syscall.CERT_E_CN_NO_MATCH", ConstUnit64, []string{"windows-386"}},
+		{"pkg syscall (linux-arm-cgo), const SIGBUS Signal", "syscall.SIGBUS", Normal, []string{"linux-arm-cgo"}},
+		{"pkg net/http, method (*Request) Clone(context.Context) *Request", "net/http.Request.Clone", Method, nil},
+		{"pkg sync/atomic, method (*Pointer[$0]) Load() *$0 #50860", "sync/atomic.Pointer.Load", Method, nil},
+		{"pkg syscall (windows-386), method (*DLLError) Unwrap() error", "syscall.DLLError.Unwrap", Method, []string{"windows-386"}},
+		{"pkg syscall (linux-386), type Stat_t struct, Ino uint64", "syscall.Stat_t.Ino", Field, []string{"linux-386"}},
+		{"pkg runtime, type BlockProfileRecord struct, embedded StackRecord", "runtime.BlockProfileRecord.StackRecord", Field, nil},
+		{"pkg go/types, type Checker struct, embedded *Info", "go/types.Checker.Info", Field, nil},
+		{"pkg os/exec, type ExitError struct, embedded *os.ProcessState", "os/exec.ExitError.ProcessState", Field, nil},
+		{"pkg crypto/ecdsa, type PublicKey struct, embedded elliptic.Curve", "crypto/ecdsa.PublicKey.Curve", Field, nil},
+		{"pkg os, type FileInfo interface, IsDir() bool", "os.FileInfo.IsDir", InterfaceMethod, nil},
+		{"pkg text/template/parse, type Node interface, unexported methods", "text/template/parse.Node", Normal, nil},
+	}
+	dir := t.TempDir()
+	for _, test := range tests {
+		api, err := LoadApi("go1.20", writeApi(t, dir, "go1.20", test.line).Files...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(api.Keys) != 1 {
+			t.Errorf("%q: keys %v, want %v", test.line, api.Keys, test.key)
+			continue
+		}
+		typ, ok := api.Keys[test.key]
+		if !ok || typ != test.typ {
+			t.Errorf("%q: keys %v, want %v %v", test.line, api.Keys, test.key, test.typ)
+		}
+		if ctxs := api.Contexts[test.key]; !reflect.DeepEqual(ctxs, test.ctxs) {
+			t.Errorf("%q: contexts %q, want %q", test.line, ctxs, test.ctxs)
+		}
+	}
+}
+
+func TestApiContexts(t *testing.T) {
+	dir := t.TempDir()
+	base := writeApi(t, dir, "go1",
+		// listed for some contexts, then for all of them by go1.20
+		"pkg syscall (linux-386), const O_SYNC = 4096",
+		"pkg syscall (linux-386-cgo), const O_SYNC = 4096",
+		// listed for all the contexts, a context line does not restrict it
+		"pkg os, const O_SYNC int",
+		"pkg os (linux-arm), const O_SYNC = 1052672",
+		// listed for some contexts by the versions
+		"pkg syscall (windows-386), type DLLError struct",
+		"pkg syscall (windows-amd64), type DLLError struct",
+	)
+	next := writeApi(t, dir, "go1.20",
+		"pkg syscall, const O_SYNC ideal-int",
+		"pkg syscall (windows-386), method (*DLLError) Unwrap() error",
+		"pkg syscall (windows-amd64), method (*DLLError) Unwrap() error",
+		"pkg syscall (linux-386), type DLLError struct",
+	)
+	ac := NewApiCheck()
+	if err := ac.LoadBase(base); err != nil {
+		t.Fatal(err)
+	}
+	if err := ac.LoadApi(next); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		key  string
+		ctxs []string
+	}{
+		{"syscall.O_SYNC", nil},
+		{"os.O_SYNC", nil},
+		{"syscall.DLLError", []string{"windows-386", "windows-amd64", "linux-386"}},
+		{"syscall.DLLError.Unwrap", []string{"windows-386", "windows-amd64"}},
+	}
+	for _, test := range tests {
+		ctxs, ok := ac.Platforms(test.key)
+		if !ok || !reflect.DeepEqual(ctxs, test.ctxs) {
+			t.Errorf("Platforms(%v) = %q %v, want %q", test.key, ctxs, ok, test.ctxs)
+		}
+	}
+	if _, ok := ac.Platforms("syscall.Unknown"); ok {
+		t.Errorf("Platforms(syscall.Unknown) is listed")
+	}
+	if v := ac.Since("syscall.DLLError.Unwrap"); v != "go1.20" {
+		t.Errorf("Since(syscall.DLLError.Unwrap) = %q, want go1.20", v)
+	}
+	if !ac.PlatformPkg("syscall") || ac.PlatformPkg("os") {
+		t.Errorf("PlatformPkg(syscall), PlatformPkg(os) = %v, %v, want true, false", ac.PlatformPkg("syscall"), ac.PlatformPkg("os"))
+	}
+
+	onContext := []struct {
+		key string
+		ctx string
+		on  bool
+	}{
+		{"syscall.DLLError.Unwrap", "windows-386", true},
+		// the api files check windows-386 without cgo only
+		{"syscall.DLLError.Unwrap", "windows-386-cgo", true},
+		{"syscall.DLLError.Unwrap", "linux-386", false},
+		// the api files check linux-386 with and without cgo
+		{"syscall.DLLError", "linux-386", true},
+		{"syscall.DLLError", "linux-386-cgo", false},
+		// the api files don't check js-wasm
+		{"syscall.DLLError", "js-wasm", true},
+		{"os.O_SYNC", "linux-386", true},
+		{"syscall.Unknown", "linux-386", true},
+	}
+	for _, test := range onContext {
+		if on := ac.OnContext(test.key, parseContext(test.ctx)); on != test.on {
+			t.Errorf("OnContext(%v, %v) = %v, want %v", test.key, test.ctx, on, test.on)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Instance is a configured instantiation of a generic function or type of a
+// package, one line of the config file:
+//
+//	[name =] path.Sym[T1, T2]
+//
+// The Go+ name defaults to Sym followed by the names of the type arguments.
+type Instance struct {
+	Name  string
+	Path  string
+	Sym   string
+	TArgs []ast.Expr
+	Text  string
+}
+
+func (p *Instance) String() string {
+	return p.Text
+}
+
+// LoadConfig loads the instantiations of the config file filename, blank
+// lines and lines starting with # are skipped.
+func LoadConfig(filename string) ([]*Instance, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var list []*Instance
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		inst, err := parseInstance(line)
+		if err != nil {
+			return nil, fmt.Errorf("%v:%v: %v", filename, n, err)
+		}
+		list = append(list, inst)
+	}
+	return list, sc.Err()
+}
+
+func parseInstance(line string) (*Instance, error) {
+	inst := &Instance{Text: line}
+	if i := strings.Index(line, "="); i >= 0 {
+		inst.Name = strings.TrimSpace(line[:i])
+		if !token.IsIdentifier(inst.Name) {
+			return nil, fmt.Errorf("invalid name %q", inst.Name)
+		}
+		line = strings.TrimSpace(line[i+1:])
+	}
+	i := strings.Index(line, "[")
+	if i < 0 {
+		return nil, fmt.Errorf("missing type arguments of %q", line)
+	}
+	dot := strings.LastIndex(line[:i], ".")
+	if dot < 0 {
+		return nil, fmt.Errorf("missing package of %q", line)
+	}
+	inst.Path, inst.Sym = line[:dot], line[dot+1:i]
+	if !token.IsIdentifier(inst.Sym) {
+		return nil, fmt.Errorf("invalid symbol %q", inst.Sym)
+	}
+	expr, err := parser.ParseExpr("_" + line[i:])
+	if err != nil {
+		return nil, err
+	}
+	switch x := expr.(type) {
+	case *ast.IndexExpr:
+		inst.TArgs = []ast.Expr{x.Index}
+	case *ast.IndexListExpr:
+		inst.TArgs = x.Indices
+	default:
+		return nil, fmt.Errorf("invalid type arguments of %q", line)
+	}
+	return inst, nil
+}
+
+// resolveType returns the type of the type expression expr, identifiers are
+// looked up in the scope of pkg and qualified identifiers in the packages
+// imported by pkg, or in the package of the import path imported by imp,
+// time.Time or "net/http".Header.
+func resolveType(pkg *types.Package, imp types.Importer, expr ast.Expr) (types.Type, error) {
+	switch x := expr.(type) {
+	case *ast.ParenExpr:
+		return resolveType(pkg, imp, x.X)
+	case *ast.Ident:
+		obj := pkg.Scope().Lookup(x.Name)
+		if obj == nil {
+			obj = types.Universe.Lookup(x.Name)
+		}
+		if obj, ok := obj.(*types.TypeName); ok {
+			return obj.Type(), nil
+		}
+		return nil, fmt.Errorf("undefined type %v", x.Name)
+	case *ast.SelectorExpr:
+		var dep *types.Package
+		var path string
+		switch q := x.X.(type) {
+		case *ast.Ident:
+			// a package name, or the import path of a package with the
+			// same name as time.
+			path = q.Name
+			dep = findImport(pkg, path, make(map[*types.Package]bool))
+		case *ast.BasicLit:
+			if q.Kind != token.STRING {
+				return nil, fmt.Errorf("unsupported type expression %v", types.ExprString(expr))
+			}
+			path, _ = strconv.Unquote(q.Value)
+			if path == pkg.Path() {
+				dep = pkg
+			}
+		default:
+			return nil, fmt.Errorf("unsupported type expression %v", types.ExprString(expr))
+		}
+		if dep == nil {
+			var err error
+			if dep, err = imp.Import(path); err != nil {
+				return nil, fmt.Errorf("undefined package %v, %v", path, err)
+			}
+		}
+		if obj, ok := dep.Scope().Lookup(x.Sel.Name).(*types.TypeName); ok && obj.Exported() {
+			return obj.Type(), nil
+		}
+		return nil, fmt.Errorf("undefined type %v.%v", path, x.Sel.Name)
+	case *ast.StarExpr:
+		elem, err := resolveType(pkg, imp, x.X)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewPointer(elem), nil
+	case *ast.ArrayType:
+		elem, err := resolveType(pkg, imp, x.Elt)
+		if err != nil {
+			return nil, err
+		}
+		if x.Len == nil {
+			return types.NewSlice(elem), nil
+		}
+		lit, ok := x.Len.(*ast.BasicLit)
+		if !ok || lit.Kind != token.INT {
+			break
+		}
+		n, ok := constant.Int64Val(constant.MakeFromLiteral(lit.Value, lit.Kind, 0))
+		if !ok {
+			break
+		}
+		return types.NewArray(elem, n), nil
+	case *ast.MapType:
+		key, err := resolveType(pkg, imp, x.Key)
+		if err != nil {
+			return nil, err
+		}
+		elem, err := resolveType(pkg, imp, x.Value)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewMap(key, elem), nil
+	case *ast.ChanType:
+		elem, err := resolveType(pkg, imp, x.Value)
+		if err != nil {
+			return nil, err
+		}
+		dir := types.SendRecv
+		if x.Dir == ast.SEND {
+			dir = types.SendOnly
+		} else if x.Dir == ast.RECV {
+			dir = types.RecvOnly
+		}
+		return types.NewChan(dir, elem), nil
+	case *ast.InterfaceType:
+		if x.Methods == nil || len(x.Methods.List) == 0 {
+			return anyType, nil
+		}
+	case *ast.IndexExpr, *ast.IndexListExpr:
+		var base ast.Expr
+		var indices []ast.Expr
+		if ix, ok := x.(*ast.IndexExpr); ok {
+			base, indices = ix.X, []ast.Expr{ix.Index}
+		} else {
+			ix := x.(*ast.IndexListExpr)
+			base, indices = ix.X, ix.Indices
+		}
+		typ, err := resolveType(pkg, imp, base)
+		if err != nil {
+			return nil, err
+		}
+		targs, err := resolveTypes(pkg, imp, indices)
+		if err != nil {
+			return nil, err
+		}
+		return types.Instantiate(nil, typ, targs, true)
+	}
+	return nil, fmt.Errorf("unsupported type expression %v", types.ExprString(expr))
+}
+
+func resolveTypes(pkg *types.Package, imp types.Importer, list []ast.Expr) ([]types.Type, error) {
+	var typs []types.Type
+	for _, expr := range list {
+		typ, err := resolveType(pkg, imp, expr)
+		if err != nil {
+			return nil, err
+		}
+		typs = append(typs, typ)
+	}
+	return typs, nil
+}
+
+// findImport returns the package named name imported, directly first, by
+// pkg or by the packages it depends on.
+func findImport(pkg *types.Package, name string, visited map[*types.Package]bool) *types.Package {
+	if pkg.Name() == name {
+		return pkg
+	}
+	visited[pkg] = true
+	for _, dep := range pkg.Imports() {
+		if dep.Name() == name {
+			return dep
+		}
+	}
+	for _, dep := range pkg.Imports() {
+		if visited[dep] {
+			continue
+		}
+		if found := findImport(dep, name, visited); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// instanceName returns the default Go+ name of sym instantiated with targs,
+// Index[[]string, string] is named IndexSliceStringString.
+func instanceName(sym string, targs []types.Type) string {
+	name := sym
+	for _, t := range targs {
+		name += typeArgName(t)
+	}
+	return name
+}
+
+func typeArgName(typ types.Type) string {
+	switch t := typ.(type) {
+	case *types.Basic:
+		return strings.Title(t.Name())
+	case *types.Named:
+		name := t.Obj().Name()
+		if t.Obj().Pkg() == nil {
+			// predeclared error
+			name = strings.Title(name)
+		}
+		for i := 0; i < t.TypeArgs().Len(); i++ {
+			name += typeArgName(t.TypeArgs().At(i))
+		}
+		return name
+	case *types.Pointer:
+		return "Ptr" + typeArgName(t.Elem())
+	case *types.Slice:
+		return "Slice" + typeArgName(t.Elem())
+	case *types.Array:
+		return fmt.Sprintf("Array%v", t.Len()) + typeArgName(t.Elem())
+	case *types.Map:
+		return "Map" + typeArgName(t.Key()) + typeArgName(t.Elem())
+	case *types.Chan:
+		return "Chan" + typeArgName(t.Elem())
+	case *types.Interface:
+		if t.Empty() {
+			return "Any"
+		}
+	case interface{ Obj() *types.TypeName }:
+		// type alias
+		return strings.Title(t.Obj().Name())
+	}
+	var name []rune
+	for _, r := range typ.String() {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			name = append(name, r)
+		}
+	}
+	return string(name)
+}
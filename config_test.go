@@ -0,0 +1,101 @@
+package main
+
+import (
+	"go/types"
+	"strings"
+	"testing"
+)
+
+func TestParseInstance(t *testing.T) {
+	tests := []struct {
+		line  string
+		name  string
+		path  string
+		sym   string
+		targs []string
+	}{
+		{"slices.Index[[]string, string]", "", "slices", "Index", []string{"[]string", "string"}},
+		{"IndexInt = slices.Index[[]int, int]", "IndexInt", "slices", "Index", []string{"[]int", "int"}},
+		{"sync/atomic.Pointer[int]", "", "sync/atomic", "Pointer", []string{"int"}},
+		{"sync/atomic.Pointer[time.Time]", "", "sync/atomic", "Pointer", []string{"time.Time"}},
+		{`sync/atomic.Pointer["net/http".Header]`, "", "sync/atomic", "Pointer", []string{`"net/http".Header`}},
+		{"gen/tp.Pair[string, List[int]]", "", "gen/tp", "Pair", []string{"string", "List[int]"}},
+		{"maps.Keys[map[string][]int]", "", "maps", "Keys", []string{"map[string][]int"}},
+		{"P = gen/tp.Pair[List[Pair[int, string]], *int]", "P", "gen/tp", "Pair", []string{"List[Pair[int, string]]", "*int"}},
+	}
+	for _, test := range tests {
+		inst, err := parseInstance(test.line)
+		if err != nil {
+			t.Errorf("parseInstance(%q): %v", test.line, err)
+			continue
+		}
+		var targs []string
+		for _, expr := range inst.TArgs {
+			targs = append(targs, types.ExprString(expr))
+		}
+		if inst.Name != test.name || inst.Path != test.path || inst.Sym != test.sym ||
+			strings.Join(targs, "; ") != strings.Join(test.targs, "; ") {
+			t.Errorf("parseInstance(%q) = %q %q %q %q, want %q %q %q %q", test.line,
+				inst.Name, inst.Path, inst.Sym, targs, test.name, test.path, test.sym, test.targs)
+		}
+	}
+}
+
+func TestParseInstanceError(t *testing.T) {
+	tests := []string{
+		"slices.Index",
+		"slices.Index[]",
+		"slices.Index[[]string, string",
+		"slices.Index[[]string]]",
+		"slices.Index[int](0)",
+		"Index[int]",
+		"slices.[int]",
+		"a b = slices.Index[[]int, int]",
+		"= slices.Index[[]int, int]",
+	}
+	for _, line := range tests {
+		if inst, err := parseInstance(line); err == nil {
+			t.Errorf("parseInstance(%q) = %v %v %v, want error", line, inst.Path, inst.Sym, inst.TArgs)
+		}
+	}
+}
+
+func TestInstanceName(t *testing.T) {
+	p := types.NewPackage("gen/p", "p")
+	q := types.NewPackage("gen/q", "q")
+	named := func(pkg *types.Package, name string) *types.Named {
+		return types.NewNamed(types.NewTypeName(0, pkg, name, nil), types.Typ[types.Int], nil)
+	}
+	tparam := types.NewTypeParam(types.NewTypeName(0, p, "E", nil), anyType)
+	list := types.NewNamed(types.NewTypeName(0, p, "List", nil), nil, nil)
+	list.SetTypeParams([]*types.TypeParam{tparam})
+	list.SetUnderlying(types.NewSlice(tparam))
+	listInt, err := types.Instantiate(nil, list, []types.Type{types.Typ[types.Int]}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	str, i := types.Typ[types.String], types.Typ[types.Int]
+	tests := []struct {
+		sym   string
+		targs []types.Type
+		name  string
+	}{
+		{"Index", []types.Type{types.NewSlice(str), str}, "IndexSliceStringString"},
+		{"Keys", []types.Type{types.NewMap(str, i)}, "KeysMapStringInt"},
+		{"Pointer", []types.Type{types.NewPointer(i)}, "PointerPtrInt"},
+		{"Sum", []types.Type{types.NewArray(types.Universe.Lookup("byte").Type(), 4)}, "SumArray4Byte"},
+		{"Send", []types.Type{types.NewChan(types.SendRecv, i)}, "SendChanInt"},
+		{"Pointer", []types.Type{anyType}, "PointerAny"},
+		{"Pointer", []types.Type{types.Universe.Lookup("error").Type()}, "PointerError"},
+		{"List", []types.Type{listInt}, "ListListInt"},
+		// the package of a named type is dropped, p.T and q.T collide, the
+		// config names one of them.
+		{"List", []types.Type{named(p, "T")}, "ListT"},
+		{"List", []types.Type{named(q, "T")}, "ListT"},
+	}
+	for _, test := range tests {
+		if name := instanceName(test.sym, test.targs); name != test.name {
+			t.Errorf("instanceName(%v, %v) = %v, want %v", test.sym, test.targs, name, test.name)
+		}
+	}
+}
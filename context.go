@@ -3,6 +3,8 @@ package main
 import (
 	"go/build"
 	"log"
+	"os"
+	"os/exec"
 	"strings"
 )
 
@@ -63,3 +65,68 @@ func setCustomContexts(customctx string) {
 		contexts = append(contexts, parseContext(c))
 	}
 }
+
+// contextEnv returns the environment of the go command loading packages for
+// the context c.
+func contextEnv(c *build.Context) []string {
+	cgo := "0"
+	if c.CgoEnabled {
+		cgo = "1"
+	}
+	return append(os.Environ(), "GOOS="+c.GOOS, "GOARCH="+c.GOARCH, "CGO_ENABLED="+cgo)
+}
+
+// cgoVariants reports whether the contexts have both a cgo and a non-cgo
+// context for the os and arch of c.
+func cgoVariants(c *build.Context) bool {
+	for _, v := range contexts {
+		if v.GOOS == c.GOOS && v.GOARCH == c.GOARCH && v.CgoEnabled != c.CgoEnabled {
+			return true
+		}
+	}
+	return false
+}
+
+// platformName returns the name of the generated files of the context c,
+// <goos>-<goarch> followed by -cgo or -nocgo if the contexts have both.
+func platformName(c *build.Context) string {
+	s := osArchName(c)
+	if cgoVariants(c) {
+		if c.CgoEnabled {
+			return s + "-cgo"
+		}
+		return s + "-nocgo"
+	}
+	return s
+}
+
+// buildTerm returns the +build term satisfied by the context c.
+func buildTerm(c *build.Context) string {
+	s := c.GOOS + "," + c.GOARCH
+	if cgoVariants(c) {
+		if c.CgoEnabled {
+			return s + ",cgo"
+		}
+		return s + ",!cgo"
+	}
+	return s
+}
+
+var distList map[string]bool
+
+// supportedContext reports whether the go command supports the os and arch
+// of the context c, listed by go tool dist list.
+func supportedContext(c *build.Context) bool {
+	if distList == nil {
+		distList = make(map[string]bool)
+		out, err := exec.Command("go", "tool", "dist", "list").Output()
+		if err != nil {
+			log.Println("warning, go tool dist list", err)
+			return true
+		}
+		for _, v := range strings.Fields(string(out)) {
+			distList[v] = true
+		}
+	}
+	return len(distList) == 0 || distList[c.GOOS+"/"+c.GOARCH]
+}
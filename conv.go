@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"strconv"
+)
+
+// argExpr returns the expression converting the interface{} value arg, the
+// argument index of fn, to typ.
+func argExpr(f *File, fn string, arg string, index string, typ types.Type) string {
+	if isEmptyInterface(typ) {
+		return arg
+	}
+	return convExpr(f, arg, strconv.Quote(fn), index, typ)
+}
+
+// convExpr is like argExpr with fn being an expression.
+func convExpr(f *File, arg string, fn string, index string, typ types.Type) string {
+	if isEmptyInterface(typ) {
+		return arg
+	}
+	return fmt.Sprintf("%v(%v, %v, %v)", f.argConv(typ), arg, fn, index)
+}
+
+func isEmptyInterface(typ types.Type) bool {
+	iface, ok := typ.Underlying().(*types.Interface)
+	return ok && iface.NumMethods() == 0 && iface.IsMethodSet()
+}
+
+// argConv returns the name of the helper converting an argument of a
+// function to typ. Values of typ are used as is, an untyped nil is accepted
+// for nillable types and numeric values are converted if no precision is
+// lost, other values raise an error naming the function and argument.
+func (f *File) argConv(typ types.Type) string {
+	if _, ok := typ.Underlying().(*types.Signature); ok {
+		return f.funcAdapter(typ)
+	}
+	return f.helper("qarg", typeKey(typ), func(name string) string {
+		it := f.TypeString(typ)
+		var decl string
+		decl += fmt.Sprintf("// %v converts the argument v of fn to %v.\n", name, it)
+		decl += fmt.Sprintf("func %v(v interface{}, fn string, i int) %v {\n", name, it)
+		if _, ok := typ.Underlying().(*types.Interface); ok {
+			decl += fmt.Sprintf("\tif t, ok := v.(%v); ok || v == nil {\n\t\treturn t\n\t}\n", it)
+			decl += fmt.Sprintf("\tpanic(%v(v, fn, i, %q))\n", f.runtime("qargError"), it)
+		} else {
+			decl += "\tswitch t := v.(type) {\n"
+			decl += fmt.Sprintf("\tcase %v:\n\t\treturn t\n", it)
+			decl += f.containerConv(typ)
+			decl += "\t}\n"
+			decl += fmt.Sprintf("\treturn %v(v, fn, i, %v.TypeOf((*%v)(nil)).Elem()).(%v)\n", f.runtime("qconv"), f.Import("reflect", "reflect"), it, it)
+			f.runtime("qargError")
+		}
+		decl += "}"
+		return decl
+	})
+}
+
+// recvConv returns the name of the helper converting the receiver of a value
+// method to named, dereferencing *named as Go does for addressable values.
+func (f *File) recvConv(named *types.Named) string {
+	return f.helper("qrecv", "recv "+typeKey(named), func(name string) string {
+		it := f.TypeString(named)
+		var decl string
+		decl += fmt.Sprintf("// %v converts the receiver v of fn to %v.\n", name, it)
+		decl += fmt.Sprintf("func %v(v interface{}, fn string, i int) %v {\n", name, it)
+		decl += "\tswitch t := v.(type) {\n"
+		decl += fmt.Sprintf("\tcase %v:\n\t\treturn t\n", it)
+		decl += fmt.Sprintf("\tcase *%v:\n\t\tif t != nil {\n\t\t\treturn *t\n\t\t}\n", it)
+		decl += "\t}\n"
+		decl += fmt.Sprintf("\tpanic(%v(v, fn, i, %q))\n", f.runtime("qargError"), it)
+		decl += "}"
+		return decl
+	})
+}
+
+var (
+	anyType       = types.NewInterfaceType(nil, nil)
+	anySliceType  = types.NewSlice(anyType)
+	anyMapType    = types.NewMap(anyType, anyType)
+	stringMapType = types.NewMap(types.Typ[types.String], anyType)
+)
+
+// containerConv returns the type switch cases converting the untyped
+// containers of Go+, []interface{} and maps of interface{} values, to the
+// slice, array or map typ element by element.
+func (f *File) containerConv(typ types.Type) string {
+	it := f.TypeString(typ)
+	var cases string
+	switch t := typ.Underlying().(type) {
+	case *types.Slice:
+		if types.Identical(typ, anySliceType) {
+			break
+		}
+		cases += "\tcase []interface{}:\n"
+		cases += fmt.Sprintf("\t\tret := make(%v, len(t))\n", it)
+		cases += "\t\tfor j, e := range t {\n"
+		cases += fmt.Sprintf("\t\t\tret[j] = %v\n", convExpr(f, "e", "fn", "i", t.Elem()))
+		cases += "\t\t}\n"
+		cases += "\t\treturn ret\n"
+	case *types.Array:
+		cases += "\tcase []interface{}:\n"
+		cases += fmt.Sprintf("\t\tif len(t) == %v {\n", t.Len())
+		cases += fmt.Sprintf("\t\t\tvar ret %v\n", it)
+		cases += "\t\t\tfor j, e := range t {\n"
+		cases += fmt.Sprintf("\t\t\t\tret[j] = %v\n", convExpr(f, "e", "fn", "i", t.Elem()))
+		cases += "\t\t\t}\n"
+		cases += "\t\t\treturn ret\n"
+		cases += "\t\t}\n"
+	case *types.Map:
+		for _, mt := range []*types.Map{anyMapType, stringMapType} {
+			if types.Identical(typ, mt) {
+				continue
+			}
+			cases += fmt.Sprintf("\tcase %v:\n", mt)
+			cases += fmt.Sprintf("\t\tret := make(%v, len(t))\n", it)
+			cases += "\t\tfor k, e := range t {\n"
+			cases += fmt.Sprintf("\t\t\tret[%v] = %v\n", convExpr(f, "k", "fn", "i", t.Key()), convExpr(f, "e", "fn", "i", t.Elem()))
+			cases += "\t\t}\n"
+			cases += "\t\treturn ret\n"
+		}
+	}
+	return cases
+}
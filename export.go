@@ -3,181 +3,581 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"go/build"
 	"go/format"
+	"go/types"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
-
-	"golang.org/x/tools/imports"
 )
 
 func formatCode(src []byte) ([]byte, error) {
 	return format.Source(src)
 }
 
-func goimports(src []byte) ([]byte, error) {
-	return imports.Process("", src, nil)
+// shard is a generated source file of a package with its own init function.
+type shard struct {
+	f       *File
+	name    string
+	count   int
+	funcdec []string
+	consts  []string
+	vars    []string
+	types   []string
+	proxies []string
+	funcs   []string
+	funcvs  []string
+}
+
+// shards splits the output of a package into files by kind, at most split
+// symbols per file, or writes it into the single exports.go if split is 0.
+// The names of the files of a platform or Go version end with suffix, cons
+// are the +build constraints of the files.
+type shards struct {
+	root     *shard
+	split    int
+	suffix   string
+	cons     []string
+	list     []*shard
+	kinds    map[string]*shard
+	chunks   map[string]int
+	versions map[string]*shards
+	vers     []string
+}
+
+func newShards(f *File, split int, suffix string, cons []string) *shards {
+	root := &shard{f: f, name: "exports" + suffix + ".go"}
+	return &shards{
+		root:     root,
+		split:    split,
+		suffix:   suffix,
+		cons:     cons,
+		list:     []*shard{root},
+		kinds:    make(map[string]*shard),
+		chunks:   make(map[string]int),
+		versions: make(map[string]*shards),
+	}
+}
+
+// version returns the files of the symbols added in the Go version ver,
+// constrained to ver with their own helpers, or p if ver is empty.
+func (p *shards) version(ver string) *shards {
+	if ver == "" {
+		return p
+	}
+	if v, ok := p.versions[ver]; ok {
+		return v
+	}
+	suffix := "_" + ver + p.suffix
+	f := p.root.f.newScope("_" + strings.Replace(ver, ".", "_", -1) + p.suffix)
+	v := newShards(f, p.split, suffix, append([]string{ver}, p.cons...))
+	p.versions[ver] = v
+	p.vers = append(p.vers, ver)
+	return v
+}
+
+// all returns p and the files of its versions, ordered by version.
+func (p *shards) all() []*shards {
+	list := []*shards{p}
+	for _, ver := range ac.sortVers(p.vers) {
+		list = append(list, p.versions[ver])
+	}
+	return list
+}
+
+// get returns the file of the next symbol of kind.
+func (p *shards) get(kind string) *shard {
+	if p.split <= 0 {
+		return p.root
+	}
+	if s, ok := p.kinds[kind]; ok && s.count < p.split {
+		return s
+	}
+	p.chunks[kind]++
+	name := "exports_" + kind
+	if n := p.chunks[kind]; n > 1 {
+		name += "_" + strconv.Itoa(n)
+	}
+	s := &shard{f: p.root.f.newFile(), name: name + p.suffix + ".go"}
+	p.kinds[kind] = s
+	p.list = append(p.list, s)
+	return s
 }
 
 func export(pkg string, outpath string, buildTags string) error {
-	p, err := LoadGoPkg(pkg)
+	list, restricted, err := loadPlatforms(pkg)
 	if err != nil {
 		return err
 	}
+	p := list[0].pkg
 	log.Println(p.Pkg.ID)
-	p.LoadAll(true)
-	p.Sort()
+
+	// the symbols found in all the platforms with the same type, and not
+	// listed for some contexts only by the api files for the std packages,
+	// are written to exports.go, the others to the files of the platforms
+	// the api files list them for.
+	common := commonKeys(list)
+	path := p.Pkg.Types.Path()
+	apiPkg := ac.Pkgs[path]
+	isCommon := func(sym interface{}) bool {
+		return common[symKey(sym)] && apiCommon(path, sym)
+	}
+	var cons, tags []string
+	if buildTags != "" {
+		tags = []string{buildTags}
+	}
+	if restricted {
+		var terms []string
+		for _, v := range list {
+			terms = append(terms, buildTerm(v.ctx))
+		}
+		cons = append(cons, strings.Join(terms, " "))
+	}
+	out := newShards(NewFile(), flagSplit, "", append(cons, tags...))
+	manifest := out.add(p, isCommon, "")
+	outs := out.all()
+	if len(list) > 1 || apiPkg {
+		for _, v := range list {
+			name := platformName(v.ctx)
+			suffix := "_" + strings.Replace(name, "-", "_", -1)
+			ps := newShards(out.root.f.newScope(suffix), flagSplit, suffix, append([]string{buildTerm(v.ctx)}, tags...))
+			ctx := v.ctx
+			manifest = append(manifest, ps.add(v.pkg, func(sym interface{}) bool {
+				return !isCommon(sym) && apiOnContext(path, sym, ctx)
+			}, " "+name)...)
+			outs = append(outs, ps.all()...)
+		}
+	}
+
+	// write root dir
+	root := filepath.Join(outpath, pkg)
+	os.MkdirAll(root, 0777)
+
+	// remove the files of a previous export with another split.
+	olds, _ := filepath.Glob(filepath.Join(root, "exports*.go"))
+	for _, old := range olds {
+		os.Remove(old)
+	}
+
+	for _, o := range outs {
+		for _, s := range o.list {
+			var newPackage string
+			if s == out.root {
+				newPackage = fmt.Sprintf("var I = %v.NewGoPackage(%q)", s.f.Import(qlang_lib, qlang_def), p.Pkg.Types.Path())
+			} else if s.count == 0 {
+				continue
+			}
+			data, err := s.source(buildHeads(p.Pkg.Types.Name(), o.cons), newPackage)
+			if err != nil {
+				return err
+			}
+			err = ioutil.WriteFile(filepath.Join(root, s.name), data, 0666)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// write the manifest of the registered symbols, one per line: the kind,
+	// the Go+ name, "reflective" for the funcs called by reflection and the
+	// platform of the symbols not found in all the platforms.
+	header := fmt.Sprintf("# %v\n", p.Pkg.Types.Path())
+	err = ioutil.WriteFile(filepath.Join(root, "manifest.txt"), []byte(header+strings.Join(manifest, "\n")+"\n"), 0666)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// buildHeads returns the heads of the generated files of the package, a build
+// constraint line for each of cons.
+func buildHeads(pkgName string, cons []string) []string {
+	var heads []string
+	for _, c := range cons {
+		heads = append(heads, "// +build "+c)
+	}
+	if len(heads) > 0 {
+		heads[len(heads)-1] += "\n"
+	}
+	return append(heads, fmt.Sprintf("package %v\n", pkgName))
+}
+
+// add adds the symbols of p accepted by keep to the files, and returns their
+// manifest lines ending with tag.
+func (out *shards) add(p *GoPkg, keep func(sym interface{}) bool, tag string) []string {
+	var manifest []string
+	path := p.Pkg.Types.Path()
 
 	// export const
-	var consts []string
-	consts = append(consts, "I.RegisterConsts(")
 	for _, v := range p.Consts {
-		if !filterSym(v.Name()) {
+		if !filterSym(v.Name()) || !keep(v) {
+			continue
+		}
+		o, since := out.since(path, v)
+		if o == nil {
+			exclude(path, v, since)
 			continue
 		}
-		info, err := v.ExportRegister()
+		s := o.get("const")
+		info, err := v.ExportRegister(s.f)
 		if err != nil {
 			log.Printf("warning, skip const %v, %v\n", v.id, err)
 			continue
 		}
-		consts = append(consts, "\t"+info+",")
+		s.consts = append(s.consts, "\t"+info+",")
+		s.count++
+		manifest = append(manifest, "const "+v.Name()+since+tag)
 	}
-	consts = append(consts, ")")
 
 	// export var
-	var vars []string
-	vars = append(vars, "I.RegisterVars(")
 	for _, v := range p.Vars {
-		if !filterSym(v.Name()) {
+		if !filterSym(v.Name()) || !keep(v) {
 			continue
 		}
-		info, err := v.ExportRegister()
+		o, since := out.since(path, v)
+		if o == nil {
+			exclude(path, v, since)
+			continue
+		}
+		s := o.get("var")
+		info, err := v.ExportRegister(s.f)
 		if err != nil {
 			log.Printf("warning, skip var %v, %v\n", v.id, err)
 			continue
 		}
-		vars = append(vars, "\t"+info+",")
+		s.vars = append(s.vars, "\t"+info+",")
+		s.count++
+		manifest = append(manifest, "var "+v.Name()+since+tag)
 	}
-	vars = append(vars, ")")
 
 	// export type
-	var types []string
-	types = append(types, "I.RegisterTypes(")
 	for _, v := range p.Types {
-		if !filterSym(v.Name()) {
+		if !filterSym(v.Name()) || !keep(v) {
+			continue
+		}
+		if err := v.Check(); err != nil {
+			log.Printf("warning, skip type %v, %v\n", v.Name(), err)
+			continue
+		}
+		o, since := out.since(path, v)
+		if o == nil {
+			exclude(path, v, since)
 			continue
 		}
-		info, err := v.ExportRegister()
+		s := o.get("type")
+		info, err := v.ExportRegister(s.f)
 		if err != nil {
 			log.Printf("warning, skip type %v, %v\n", v.id, err)
 			continue
 		}
-		types = append(types, "\t"+info+",")
+		s.types = append(s.types, "\t"+info+",")
+		s.count++
+		manifest = append(manifest, "type "+v.Name()+since+tag)
+	}
+
+	// export interface proxy
+	for _, v := range p.Proxies {
+		if !filterSym(v.Name()) || !keep(v) {
+			continue
+		}
+		o, since := out.since(path, v)
+		if o == nil {
+			exclude(path, v, since)
+			continue
+		}
+		s := o.get("type")
+		decl, err := v.ExportDecl(s.f)
+		if err != nil {
+			log.Printf("warning, skip proxy %v, %v\n", v.id, err)
+			continue
+		}
+		s.funcdec = append(s.funcdec, decl)
+		info, _ := v.ExportRegister(s.f)
+		s.proxies = append(s.proxies, "\t"+info+",")
+		s.count++
+		manifest = append(manifest, "func "+v.qRegName()+since+tag)
 	}
-	types = append(types, ")")
 
 	// export func
-	var funcreg []string
-	var funcvreg []string
-	var funcdec []string
-	funcreg = append(funcreg, "I.RegisterFuncs(")
-	funcvreg = append(funcvreg, "I.RegisterFuncvs(")
+	var last *shard
 	for _, v := range p.Funcs {
-		if !filterSym(v.Name()) {
+		if !filterSym(v.Name()) || !keep(v) {
 			continue
 		}
-		decl, err := v.ExportDecl()
+		if err := v.Check(); err != nil {
+			if !v.CanReflect() {
+				log.Printf("warning, skip func %v, %v\n", v.Name(), err)
+				continue
+			}
+			log.Printf("warning, reflective func %v, %v\n", v.Name(), err)
+			v.reflective = true
+		}
+		// the value methods registered for *recv use the exec function of
+		// recv, declared by the previous file.
+		o, since := out.since(path, v)
+		if o == nil {
+			exclude(path, v, since)
+			continue
+		}
+		s := last
+		if !v.shared || s == nil {
+			if v.recv != nil {
+				s = o.get("method")
+			} else {
+				s = o.get("func")
+			}
+		}
+		decl, err := v.ExportDecl(s.f)
 		if err != nil {
 			log.Printf("warning, skip func %v, %v\n", v.id, err)
 			continue
 		}
-		funcdec = append(funcdec, decl)
-		info, _ := v.ExportRegister()
+		last = s
+		if decl != "" {
+			s.funcdec = append(s.funcdec, decl)
+		}
+		info, _ := v.ExportRegister(s.f)
 		if v.Variadic() {
-			funcvreg = append(funcvreg, "\t"+info+",")
+			s.funcvs = append(s.funcvs, "\t"+info+",")
+		} else {
+			s.funcs = append(s.funcs, "\t"+info+",")
+		}
+		s.count++
+		if v.reflective {
+			manifest = append(manifest, "func "+v.qRegName()+" reflective"+since+tag)
 		} else {
-			funcreg = append(funcreg, "\t"+info+",")
+			manifest = append(manifest, "func "+v.qRegName()+since+tag)
 		}
 	}
-	funcreg = append(funcreg, ")")
-	funcvreg = append(funcvreg, ")")
-
-	var heads []string
-	if pkg == "syscall/js" {
-		heads = append(heads, "// +build js\n")
+	for _, v := range p.Fields {
+		if !filterSym(v.Name()) || !keep(v) {
+			continue
+		}
+		if err := v.Check(); err != nil {
+			log.Printf("warning, skip field %v, %v\n", v.qRegName(), err)
+			continue
+		}
+		o, since := out.since(path, v)
+		if o == nil {
+			exclude(path, v, since)
+			continue
+		}
+		s := o.get("method")
+		decl, err := v.ExportDecl(s.f)
+		if err != nil {
+			log.Printf("warning, skip field %v, %v\n", v.qRegName(), err)
+			continue
+		}
+		s.funcdec = append(s.funcdec, decl)
+		info, _ := v.ExportRegister(s.f)
+		s.funcs = append(s.funcs, "\t"+info+",")
+		s.count++
+		manifest = append(manifest, "func "+v.qRegName()+since+tag)
 	}
+	return manifest
+}
 
-	heads = append(heads, fmt.Sprintf("package %v\n", p.Pkg.Types.Name()))
-	heads = append(heads, "import (")
-	heads = append(heads, fmt.Sprintf("\t%q", p.Pkg.Types.Path()))
-	heads = append(heads, "\t\"reflect\"")
-
-	if qspec == qspec_def {
-		heads = append(heads, fmt.Sprintf("\t%q", qspec_lib))
-	} else {
-		heads = append(heads, fmt.Sprintf("\t%v %q", qspec, qspec_lib))
-	}
-	if qexec == qexec_def {
-		heads = append(heads, fmt.Sprintf("\t%q", qexec_lib))
-	} else {
-		heads = append(heads, fmt.Sprintf("\t%v %q", qexec, qexec_lib))
-	}
-	if qlang == qlang_def {
-		heads = append(heads, fmt.Sprintf("\t%q", qlang_lib))
-	} else {
-		heads = append(heads, fmt.Sprintf("\t%v %q", qspec, qspec_lib))
+// source returns the formatted source of the file, newPackage declares the
+// package instance I in the root file.
+func (s *shard) source(heads []string, newPackage string) ([]byte, error) {
+	register := func(name string, list []string) []string {
+		if len(list) == 0 {
+			return nil
+		}
+		return append(append([]string{"I." + name + "("}, list...), ")")
 	}
-	heads = append(heads, ")")
+	var inits []string
+	inits = append(inits, register("RegisterConsts", s.consts)...)
+	inits = append(inits, register("RegisterVars", s.vars)...)
+	inits = append(inits, register("RegisterTypes", s.types)...)
+	inits = append(inits, register("RegisterFuncs", s.proxies)...)
+	inits = append(inits, register("RegisterFuncs", s.funcs)...)
+	inits = append(inits, register("RegisterFuncvs", s.funcvs)...)
 
 	var buf bytes.Buffer
+	decls := s.f.Decls()
 	buf.WriteString(strings.Join(heads, "\n"))
+	buf.WriteString(s.f.ImportDecl())
 	buf.WriteString("\n\n")
-	buf.WriteString(strings.Join(funcdec, "\n"))
-	buf.WriteString("\n\n")
-	buf.WriteString("// I is a Go package instance.\n")
-	buf.WriteString(fmt.Sprintf("var I = %v.NewGoPackage(%q)", qlang, p.Pkg.Types.Path()))
-	buf.WriteString("\n\n")
-	buf.WriteString("func init(){\n")
-	if len(consts) > 2 {
-		buf.WriteString(strings.Join(consts, "\n"))
-		buf.WriteString("\n")
+	if len(s.funcdec) > 0 {
+		buf.WriteString(strings.Join(s.funcdec, "\n"))
+		buf.WriteString("\n\n")
 	}
-	if len(vars) > 2 {
-		buf.WriteString(strings.Join(vars, "\n"))
-		buf.WriteString("\n")
+	if len(decls) > 0 {
+		buf.WriteString(strings.Join(decls, "\n\n"))
+		buf.WriteString("\n\n")
 	}
-	if len(types) > 2 {
-		buf.WriteString(strings.Join(types, "\n"))
-		buf.WriteString("\n")
+	if newPackage != "" {
+		buf.WriteString("// I is a Go package instance.\n")
+		buf.WriteString(newPackage)
+		buf.WriteString("\n\n")
 	}
-	if len(funcreg) > 2 {
-		buf.WriteString(strings.Join(funcreg, "\n"))
-		buf.WriteString("\n")
+	if len(inits) > 0 {
+		buf.WriteString("func init(){\n")
+		buf.WriteString(strings.Join(inits, "\n"))
+		buf.WriteString("\n}")
 	}
-	if len(funcvreg) > 2 {
-		buf.WriteString(strings.Join(funcvreg, "\n"))
-	}
-	buf.WriteString("}")
 
 	// format
-	data, err := goimports(buf.Bytes())
+	data, err := formatCode(buf.Bytes())
 	if err != nil {
 		fmt.Println(buf.String())
-		return err
+		return nil, err
 	}
-	//fmt.Println(string(data))
+	return data, nil
+}
 
-	// write root dir
-	root := filepath.Join(outpath, pkg)
-	os.MkdirAll(root, 0777)
+// since returns the files of the symbol sym of the package path by the Go
+// version adding it, and the version of its manifest line. The files are nil
+// if the version is after the target flagGoVersion.
+func (p *shards) since(path string, sym interface{}) (*shards, string) {
+	var ver string
+	for _, name := range apiNames(path, sym) {
+		if v := ac.Since(name); apiVersion(v) > apiVersion(ver) {
+			ver = v
+		}
+	}
+	if ver == "" {
+		return p, ""
+	}
+	if flagGoVersion != "" {
+		return nil, " " + ver
+	}
+	return p.version(ver), " " + ver
+}
 
-	file, err := os.Create(filepath.Join(root, "exports.go"))
-	if err != nil {
-		return err
+// excluded are the symbols of the packages not exported as added after the
+// target flagGoVersion.
+var excluded = make(map[string][]string)
+
+func exclude(path string, sym interface{}, since string) {
+	name := symKind(sym) + " " + symName(sym) + since
+	for _, v := range excluded[path] {
+		if v == name {
+			return
+		}
 	}
-	file.Write(data)
-	file.Close()
+	excluded[path] = append(excluded[path], name)
+}
 
-	return nil
+// symKind and symName return the kind and Go+ name of the manifest line of
+// the symbol.
+func symKind(sym interface{}) string {
+	switch sym.(type) {
+	case *GoConst:
+		return "const"
+	case *GoVar:
+		return "var"
+	case *GoType:
+		return "type"
+	}
+	return "func"
+}
+
+func symName(sym interface{}) string {
+	switch v := sym.(type) {
+	case *GoConst:
+		return v.Name()
+	case *GoVar:
+		return v.Name()
+	case *GoType:
+		return v.Name()
+	case *GoProxy:
+		return v.qRegName()
+	case *GoFunc:
+		return v.qRegName()
+	case *GoField:
+		return v.qRegName()
+	}
+	panic(fmt.Sprintf("unknown symbol %T", sym))
+}
+
+// apiCommon reports whether the api files list the symbol sym of the package
+// path for all the contexts, the names of sym they don't list are not
+// constrained.
+func apiCommon(path string, sym interface{}) bool {
+	for _, name := range apiNames(path, sym) {
+		if ctxs, ok := ac.Platforms(name); ok && ctxs != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// apiOnContext reports whether the api files list all the names of the symbol
+// sym of the package path for the context c.
+func apiOnContext(path string, sym interface{}, c *build.Context) bool {
+	for _, name := range apiNames(path, sym) {
+		if !ac.OnContext(name, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// apiNames returns the names in the api files the symbol of the package path
+// depends on, pkg.Type and pkg.Type.Name for methods and fields and the
+// methods of the interface of a proxy.
+func apiNames(path string, sym interface{}) []string {
+	switch v := sym.(type) {
+	case *GoConst:
+		return []string{path + "." + v.obj.Name()}
+	case *GoVar:
+		return []string{path + "." + v.obj.Name()}
+	case *GoType:
+		return []string{path + "." + v.obj.Name()}
+	case *GoProxy:
+		name := path + "." + v.named.Obj().Name()
+		names := []string{name}
+		iface := v.named.Underlying().(*types.Interface)
+		for i := 0; i < iface.NumMethods(); i++ {
+			names = append(names, name+"."+iface.Method(i).Name())
+		}
+		return names
+	case *GoFunc:
+		if v.recv != nil {
+			return memberNames(v.recv, v.typ.Name())
+		}
+		return []string{path + "." + v.obj.Name()}
+	case *GoField:
+		return memberNames(v.recv, v.typ.Name())
+	}
+	panic(fmt.Sprintf("unknown symbol %T", sym))
+}
+
+// memberNames returns the names in the api files of the member name of the
+// named type, T and T.Name. A promoted member is resolved through the embedded
+// fields, T.E, to the type declaring it, E.Name, as the api files list the
+// promoted fields only by the embedded type.
+func memberNames(named *types.Named, name string) []string {
+	obj := named.Obj()
+	names := []string{obj.Pkg().Path() + "." + obj.Name()}
+	_, index, _ := types.LookupFieldOrMethod(named, true, obj.Pkg(), name)
+	var t types.Type = named
+	for i := 0; i+1 < len(index); i++ {
+		st, ok := t.Underlying().(*types.Struct)
+		if !ok {
+			break
+		}
+		f := st.Field(index[i])
+		names = appendMemberName(names, t, f.Name())
+		t = f.Type()
+		if ptr, ok := t.(*types.Pointer); ok {
+			t = ptr.Elem()
+		}
+	}
+	return appendMemberName(names, t, name)
+}
+
+func appendMemberName(names []string, t types.Type, name string) []string {
+	named, ok := t.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil || !named.Obj().Exported() {
+		return names
+	}
+	obj := named.Obj()
+	return append(names, obj.Pkg().Path()+"."+obj.Name()+"."+name)
 }
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// File collects the imports and the helper functions shared by the exec
+// functions of a generated source file.
+type File struct {
+	imports  map[string]string // import path -> local name
+	pkgnames map[string]string // import path -> package name
+	names    map[string]string // local name -> import path
+	used     map[string]bool
+	decls    []string
+	*fileSet
+	*helperScope
+}
+
+// fileSet is shared by the files of a package, the runtimes are declared by
+// the root file.
+type fileSet struct {
+	root     *File
+	runtimes map[string]bool
+}
+
+// helperScope is shared by the files built together, a helper is declared by
+// the file first using it. The files of a platform have their own scope, the
+// names of its helpers end with suffix.
+type helperScope struct {
+	helpers map[string]string // helper key -> helper name
+	count   map[string]int    // helper prefix -> number of helpers
+	suffix  string
+}
+
+func NewFile() *File {
+	set := &fileSet{
+		runtimes: make(map[string]bool),
+	}
+	set.root = newFile(set, newHelperScope(""))
+	return set.root
+}
+
+func newHelperScope(suffix string) *helperScope {
+	return &helperScope{
+		helpers: make(map[string]string),
+		count:   make(map[string]int),
+		suffix:  suffix,
+	}
+}
+
+// newFile returns a new file of the package of f sharing its helpers.
+func (f *File) newFile() *File {
+	return newFile(f.fileSet, f.helperScope)
+}
+
+// newScope returns a new file of the package of f with its own helpers,
+// named with suffix.
+func (f *File) newScope(suffix string) *File {
+	return newFile(f.fileSet, newHelperScope(suffix))
+}
+
+func newFile(set *fileSet, scope *helperScope) *File {
+	f := &File{
+		imports:     make(map[string]string),
+		pkgnames:    make(map[string]string),
+		names:       make(map[string]string),
+		used:        make(map[string]bool),
+		fileSet:     set,
+		helperScope: scope,
+	}
+	// reserve the names used by the generated code.
+	f.reserve("fmt", "fmt", "fmt")
+	f.reserve("reflect", "reflect", "reflect")
+	f.reserve("math/big", "big", "big")
+	f.reserve(qlang_lib, qlang_def, qlang)
+	f.reserve(qspec_lib, qspec_def, qspec)
+	f.reserve(qexec_lib, qexec_def, qexec)
+	return f
+}
+
+func (f *File) reserve(path string, pkgname string, name string) {
+	f.imports[path] = name
+	f.pkgnames[path] = pkgname
+	f.names[name] = path
+}
+
+// localIdent matches the local identifiers of the generated code which
+// would shadow an imported package.
+var localIdent = regexp.MustCompile(`^(p|args|arg|arity|ret\d*|conv|fn|i|j|k|v|t|e|obj|x|a\d+|r\d+|I|q(arg|func|recv)\d+(_\w+)?)$`)
+
+// Import returns the local name of the package path named pkgname, adding
+// it to the imports of the file with a collision-free name.
+func (f *File) Import(path string, pkgname string) string {
+	f.used[path] = true
+	if name, ok := f.imports[path]; ok {
+		return name
+	}
+	name := pkgname
+	for n := 1; f.names[name] != "" || localIdent.MatchString(name) || runtimes[name] != ""; n++ {
+		name = pkgname + strconv.Itoa(n)
+	}
+	f.imports[path] = name
+	f.pkgnames[path] = pkgname
+	f.names[name] = path
+	return name
+}
+
+// Qualifier is the types.Qualifier of the file, importing pkg.
+func (f *File) Qualifier(pkg *types.Package) string {
+	return f.Import(pkg.Path(), pkg.Name())
+}
+
+func (f *File) TypeString(typ types.Type) string {
+	return types.TypeString(typ, f.Qualifier)
+}
+
+// ObjName returns the qualified name of the package level object obj.
+func (f *File) ObjName(obj types.Object) string {
+	return f.Qualifier(obj.Pkg()) + "." + obj.Name()
+}
+
+// ImportDecl returns the import declaration of the used packages, the
+// standard library first.
+func (f *File) ImportDecl() string {
+	if len(f.used) == 0 {
+		return ""
+	}
+	var std, others []string
+	for path := range f.used {
+		name := f.imports[path]
+		spec := fmt.Sprintf("\t%q", path)
+		if name != f.pkgnames[path] || name != path[strings.LastIndex(path, "/")+1:] {
+			spec = fmt.Sprintf("\t%v %q", name, path)
+		}
+		if strings.Contains(strings.Split(path, "/")[0], ".") {
+			others = append(others, spec)
+		} else {
+			std = append(std, spec)
+		}
+	}
+	sort.Slice(std, func(i, j int) bool { return importPath(std[i]) < importPath(std[j]) })
+	sort.Slice(others, func(i, j int) bool { return importPath(others[i]) < importPath(others[j]) })
+	specs := std
+	if len(std) > 0 && len(others) > 0 {
+		specs = append(specs, "")
+	}
+	specs = append(specs, others...)
+	return "import (\n" + strings.Join(specs, "\n") + "\n)"
+}
+
+func importPath(spec string) string {
+	return spec[strings.Index(spec, `"`):]
+}
+
+// typeKey identifies typ by its string qualified by package path, it does
+// not import the packages of typ as f.TypeString does.
+func typeKey(typ types.Type) string {
+	return types.TypeString(typ, func(pkg *types.Package) string {
+		return pkg.Path()
+	})
+}
+
+// helper returns the name of the helper identified by key, calling gen to
+// declare it the first time key is used.
+func (f *File) helper(prefix string, key string, gen func(name string) string) string {
+	if name, ok := f.helpers[key]; ok {
+		return name
+	}
+	f.count[prefix]++
+	name := prefix + strconv.Itoa(f.count[prefix]) + f.suffix
+	f.helpers[key] = name
+	f.decls = append(f.decls, gen(name))
+	return name
+}
+
+// runtime marks the runtime declaration name as used by the file.
+func (f *File) runtime(name string) string {
+	f.runtimes[name] = true
+	for _, path := range runtimeImports[name] {
+		f.root.used[path] = true
+	}
+	return name
+}
+
+// Decls returns the helper declarations of the file, and the runtime
+// declarations used by the package for the root file.
+func (f *File) Decls() []string {
+	if f != f.root {
+		return f.decls
+	}
+	var names []string
+	for name := range f.runtimes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var decls []string
+	for _, name := range names {
+		decls = append(decls, runtimes[name])
+	}
+	return append(decls, f.decls...)
+}
+
+// funcAdapter returns the name of the helper converting an interface{}
+// value to the func type typ. Go funcs of typ or of its underlying signature
+// are used as is, Go+ closures are wrapped into a Go func of typ.
+func (f *File) funcAdapter(typ types.Type) string {
+	return f.helper("qfunc", typeKey(typ), func(name string) string {
+		it := f.TypeString(typ)
+		sig := typ.Underlying().(*types.Signature)
+		var decl string
+		decl += fmt.Sprintf("// %v converts a Go+ closure to %v.\n", name, it)
+		decl += fmt.Sprintf("func %v(v interface{}, fn string, i int) %v {\n", name, it)
+		decl += "\tswitch fn := v.(type) {\n"
+		decl += "\tcase nil:\n\t\treturn nil\n"
+		decl += fmt.Sprintf("\tcase %v:\n\t\treturn fn\n", it)
+		if st := f.TypeString(sig); st != it {
+			decl += fmt.Sprintf("\tcase %v:\n\t\treturn %v(fn)\n", st, it)
+		}
+		decl += fmt.Sprintf("\tcase %v:\n", f.runtime("qclosure"))
+		decl += "\t\treturn " + f.closureLit(it, sig, "fn") + "\n"
+		decl += "\t}\n"
+		decl += fmt.Sprintf("\tpanic(%v(v, fn, i, %q))\n", f.runtime("qargError"), it)
+		decl += "}"
+		return decl
+	})
+}
+
+// closureLit returns a func literal of sig calling the Go+ closure fn, name
+// is the func type reported by result conversion errors. The values of a
+// variadic parameter are passed to fn as separate arguments.
+func (f *File) closureLit(name string, sig *types.Signature, fn string) string {
+	var params, args, results, rets []string
+	var body string
+	for i := 0; i < sig.Params().Len(); i++ {
+		pt := sig.Params().At(i).Type()
+		if sig.Variadic() && i == sig.Params().Len()-1 {
+			params = append(params, fmt.Sprintf("a%v ...%v", i, f.TypeString(pt.(*types.Slice).Elem())))
+			body += fmt.Sprintf("\t\t\targs := []interface{}{%v}\n", strings.Join(args, ", "))
+			body += fmt.Sprintf("\t\t\tfor _, v := range a%v {\n\t\t\t\targs = append(args, v)\n\t\t\t}\n", i)
+			args = []string{"args..."}
+		} else {
+			params = append(params, fmt.Sprintf("a%v %v", i, f.TypeString(pt)))
+			args = append(args, fmt.Sprintf("a%v", i))
+		}
+	}
+	if sig.Results().Len() == 0 {
+		body += fmt.Sprintf("\t\t\t%v.Call(%v)\n", fn, strings.Join(args, ", "))
+	} else {
+		body += fmt.Sprintf("\t\t\tret := %v.Call(%v)\n", fn, strings.Join(args, ", "))
+	}
+	for i := 0; i < sig.Results().Len(); i++ {
+		results = append(results, f.TypeString(sig.Results().At(i).Type()))
+	}
+	if n := len(results); n > 0 {
+		body += fmt.Sprintf("\t\t\tif len(ret) != %v {\n", n)
+		body += fmt.Sprintf("\t\t\t\tpanic(%v(ret, %q, %v))\n", f.runtime("qresultError"), name, n)
+		body += "\t\t\t}\n"
+	}
+	for i := 0; i < sig.Results().Len(); i++ {
+		rt := sig.Results().At(i).Type()
+		rets = append(rets, fmt.Sprintf("r%v", i))
+		body += fmt.Sprintf("\t\t\tr%v := %v\n", i, argExpr(f, name+" result", fmt.Sprintf("ret[%v]", i), strconv.Itoa(i), rt))
+	}
+	if len(rets) > 0 {
+		body += "\t\t\treturn " + strings.Join(rets, ", ") + "\n"
+	}
+	lit := "func(" + strings.Join(params, ", ") + ") "
+	if len(results) == 1 {
+		lit += results[0] + " "
+	} else if len(results) > 1 {
+		lit += "(" + strings.Join(results, ", ") + ") "
+	}
+	return lit + "{\n" + body + "\t\t}"
+}
@@ -1,7 +1,7 @@
 module qexport
 
-go 1.14
+go 1.18
 
-require (
-	golang.org/x/tools v0.0.0-20200522201501-cb1345f3a375
-)
+require golang.org/x/tools v0.0.0-20200522201501-cb1345f3a375
+
+require golang.org/x/xerrors v0.0.0-20191204190536-9bdfabe68543 // indirect
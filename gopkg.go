@@ -1,13 +1,17 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"go/ast"
+	"go/build"
 	"go/constant"
 	"go/types"
 	"log"
-	"os"
+	"math"
+	"math/big"
 	"sort"
+	"strconv"
 	"strings"
 
 	"golang.org/x/tools/go/packages"
@@ -44,10 +48,6 @@ func (v *GoObject) Name() string {
 	return v.id.Name
 }
 
-func (v *GoObject) FullName() string {
-	return v.obj.Pkg().Name() + "." + v.id.Name
-}
-
 type GoConst struct {
 	GoObject
 	typ *types.Const
@@ -58,21 +58,31 @@ type GoVar struct {
 	typ *types.Var
 }
 
-func (v *GoVar) ExportRegister() (string, error) {
-	return fmt.Sprintf("I.Var(%q, &%v)", v.Name(), v.FullName()), nil
+func (v *GoVar) ExportRegister(f *File) (string, error) {
+	return fmt.Sprintf("I.Var(%q, &%v)", v.Name(), f.ObjName(v.obj)), nil
 }
 
 type GoFunc struct {
 	GoObject
-	typ  *types.Func
-	recv *types.Named
+	typ   *types.Func
+	recv  *types.Named
+	rname string // Go+ name of recv
+	ptr   bool   // method is only in the method set of *recv
+	// value method registered for *recv, sharing the exec function of recv.
+	shared bool
+
+	reflective bool // called by reflection
+
+	// instantiation of a generic function, id is the Go+ name.
+	sig   *types.Signature
+	targs []types.Type
 }
 
 func (p *GoFunc) Name() string {
 	if p.recv == nil {
 		return p.id.Name
 	} else {
-		return p.recv.Obj().Name() + "." + p.id.Name
+		return p.rname + "." + p.id.Name
 	}
 }
 
@@ -84,28 +94,65 @@ func (p *GoFunc) qRegName() string {
 		if p.RecvIsPointer() {
 			info += "*"
 		}
-		return info + p.recv.Obj().Name() + ")." + p.id.Name
+		return info + p.rname + ")." + p.id.Name
 	}
 }
 
 func (p *GoFunc) CallName() string {
+	return p.callName(pkgName)
+}
+
+// callName returns the function or method expression, qualified by qf.
+func (p *GoFunc) callName(qf types.Qualifier) string {
 	if p.recv == nil {
-		return p.obj.Pkg().Name() + "." + p.id.Name
+		return qf(p.obj.Pkg()) + "." + p.typ.Name() + typeArgs(p.targs, qf)
 	} else {
 		info := "("
 		if p.RecvIsPointer() {
 			info += "*"
 		}
-		return info + p.obj.Pkg().Name() + "." + p.recv.Obj().Name() + ")." + p.id.Name
+		return info + types.TypeString(p.recv, qf) + ")." + p.typ.Name()
 	}
 }
 
-func (p *GoFunc) RecvIsPointer() bool {
+// typeArgs returns the type argument list of targs, qualified by qf.
+func typeArgs(targs []types.Type, qf types.Qualifier) string {
+	if len(targs) == 0 {
+		return ""
+	}
+	var list []string
+	for _, t := range targs {
+		list = append(list, types.TypeString(t, qf))
+	}
+	return "[" + strings.Join(list, ", ") + "]"
+}
+
+// objInfo returns the declaration of the function, with the signature of
+// the instantiation for generic functions.
+func (p *GoFunc) objInfo() string {
+	if p.targs == nil && (p.recv == nil || p.recv.TypeArgs().Len() == 0) {
+		return simpleObjInfo(p.obj)
+	}
+	return "func " + p.CallName() + strings.TrimPrefix(types.TypeString(p.Signature(), pkgName), "func")
+}
+
+// callExpr returns the function, or the method of the converted receiver
+// args[0], called by the exec function.
+func (p *GoFunc) callExpr(f *File) string {
 	if p.recv == nil {
-		return false
+		return p.callName(f.Qualifier)
+	}
+	if p.RecvIsPointer() && !p.shared {
+		return argExpr(f, p.CallName(), "args[0]", "0", types.NewPointer(p.recv)) + "." + p.typ.Name()
+	}
+	if _, ok := p.recv.Underlying().(*types.Interface); ok {
+		return argExpr(f, p.CallName(), "args[0]", "0", p.recv) + "." + p.typ.Name()
 	}
-	_, ok := p.Signature().Recv().Type().(*types.Pointer)
-	return ok
+	return fmt.Sprintf("%v(args[0], %q, 0).%v", f.recvConv(p.recv), p.CallName(), p.typ.Name())
+}
+
+func (p *GoFunc) RecvIsPointer() bool {
+	return p.recv != nil && p.ptr
 }
 
 func (p *GoFunc) Variadic() bool {
@@ -117,19 +164,37 @@ func (p *GoFunc) qExecName() string {
 	if p.recv == nil {
 		return "exec" + p.id.Name
 	} else {
-		return "execm" + p.recv.Obj().Name() + p.id.Name
+		return "execm" + p.rname + p.id.Name
 	}
 }
 
 func (p *GoFunc) Signature() *types.Signature {
+	if p.sig != nil {
+		return p.sig
+	}
 	return p.typ.Type().(*types.Signature)
 }
 
-func (v *GoFunc) ExportRegister() (string, error) {
+// Check reports an error if the signature of the function references a type
+// the generated code cannot name.
+func (p *GoFunc) Check() error {
+	if tparams := p.Signature().TypeParams(); tparams.Len() > 0 {
+		return fmt.Errorf("generic function is not instantiated by the config (%w %v)", errTypeParam, tparams.At(0))
+	}
+	return checkAccess(p.Signature())
+}
+
+// CanReflect reports whether the function can be called by reflection when
+// Check fails, that is the function is not generic.
+func (p *GoFunc) CanReflect() bool {
+	return !errors.Is(p.Check(), errTypeParam)
+}
+
+func (v *GoFunc) ExportRegister(f *File) (string, error) {
 	if v.Variadic() {
-		return fmt.Sprintf("I.Funcv(%q, %v, %v)", v.qRegName(), v.CallName(), v.qExecName()), nil
+		return fmt.Sprintf("I.Funcv(%q, %v, %v)", v.qRegName(), v.callName(f.Qualifier), v.qExecName()), nil
 	}
-	return fmt.Sprintf("I.Func(%q, %v, %v)", v.qRegName(), v.CallName(), v.qExecName()), nil
+	return fmt.Sprintf("I.Func(%q, %v, %v)", v.qRegName(), v.callName(f.Qualifier), v.qExecName()), nil
 }
 
 /*
@@ -159,20 +224,13 @@ func ToStrings(args []interface{}) []string {
 }
 */
 
-func (v *GoFunc) exportDeclV() (string, error) {
+func (v *GoFunc) exportDeclV(f *File) (string, error) {
 	var decl string
 	argLen := v.Signature().Params().Len()
 	retLen := v.Signature().Results().Len()
 	var paramList []string
 	var retList []string
-	var convfn string = `	conv := func(args []interface{}) []T {
-		ret := make([]T, len(args))
-		for i, arg := range args {
-			ret[i] = arg.(T)
-		}
-		return ret
-	}
-`
+	var convfn string
 
 	var argBase int
 	if v.recv != nil {
@@ -180,8 +238,8 @@ func (v *GoFunc) exportDeclV() (string, error) {
 		argBase = 1
 	}
 
-	decl += fmt.Sprintf("// %v\n", simpleObjInfo(v.obj))
-	decl += fmt.Sprintf("func %v(arity int, p *%v.Context) {\n", v.qExecName(), qlang)
+	decl += fmt.Sprintf("// %v\n", v.objInfo())
+	decl += fmt.Sprintf("func %v(arity int, p *%v.Context) {\n", v.qExecName(), f.Import(qlang_lib, qlang_def))
 	decl += fmt.Sprint("\targs := p.GetArgs(arity)\n")
 	if retLen >= 1 {
 		retList = append(retList, "ret")
@@ -192,7 +250,6 @@ func (v *GoFunc) exportDeclV() (string, error) {
 	paramLen := v.Signature().Params().Len()
 	for i := 0; i < paramLen; i++ {
 		iv := v.Signature().Params().At(i)
-		it := simpleType(iv.Type().String())
 		if i == paramLen-1 {
 			vt := iv.Type().(*types.Slice).Elem()
 			// switch vt.Underlying().(type) {
@@ -203,25 +260,22 @@ func (v *GoFunc) exportDeclV() (string, error) {
 			// 	convfn = strings.ReplaceAll(convfn, "T", simpleType(vt.String()))
 			// 	paramList = append(paramList, fmt.Sprintf("conv(args[%v:])...", argBase+i))
 			// }
-			ct := simpleType(vt.String())
-			if ct == "interface{}" {
-				convfn = ""
+			ct := f.TypeString(vt)
+			if types.Identical(vt, types.NewInterfaceType(nil, nil)) {
 				paramList = append(paramList, fmt.Sprintf("args[%v:]...", argBase+i))
 			} else {
-				convfn = strings.ReplaceAll(convfn, "T", simpleType(vt.String()))
+				convfn = fmt.Sprintf(`	conv := func(args []interface{}) []%[1]v {
+		ret := make([]%[1]v, len(args))
+		for i, arg := range args {
+			ret[i] = %[2]v
+		}
+		return ret
+	}
+`, ct, argExpr(f, v.CallName(), "arg", indexExpr(argBase+i, "i"), vt))
 				paramList = append(paramList, fmt.Sprintf("conv(args[%v:])...", argBase+i))
 			}
 		} else {
-			var basic string
-			switch vt := iv.Type().Underlying().(type) {
-			case *types.Basic:
-				basic = vt.String()
-			}
-			if basic != "" && basic != it {
-				paramList = append(paramList, fmt.Sprintf("%v(args[%v].(%v))", it, argBase+i, basic))
-			} else {
-				paramList = append(paramList, fmt.Sprintf("args[%v].(%v)", argBase+i, it))
-			}
+			paramList = append(paramList, argExpr(f, v.CallName(), fmt.Sprintf("args[%v]", argBase+i), strconv.Itoa(argBase+i), iv.Type()))
 		}
 	}
 	// add conv func
@@ -231,10 +285,7 @@ func (v *GoFunc) exportDeclV() (string, error) {
 	if retLen > 0 {
 		decl += strings.Join(retList, ",") + " := "
 	}
-	if v.recv != nil {
-		decl += "args[0]."
-	}
-	decl += v.CallName() + "(" + strings.Join(paramList, ", ") + ")\n"
+	decl += v.callExpr(f) + "(" + strings.Join(paramList, ", ") + ")\n"
 	if retLen > 0 {
 		decl += fmt.Sprintf("\tp.Ret(arity, %v)\n", strings.Join(retList, ","))
 	}
@@ -242,9 +293,58 @@ func (v *GoFunc) exportDeclV() (string, error) {
 	return decl, nil
 }
 
-func (v *GoFunc) ExportDecl() (string, error) {
+// indexExpr returns the expression of the argument index base+i.
+func indexExpr(base int, i string) string {
+	if base == 0 {
+		return i
+	}
+	return fmt.Sprintf("%v+%v", base, i)
+}
+
+// exportDeclR returns the exec function calling the function by reflection,
+// the arguments are converted at run time.
+func (v *GoFunc) exportDeclR(f *File) (string, error) {
+	var decl string
+	argLen := v.Signature().Params().Len()
+	if v.recv != nil {
+		argLen++ // arg[0] is recv
+	}
+	decl += fmt.Sprintf("// %v (reflective)\n", v.objInfo())
+	if v.Variadic() {
+		decl += fmt.Sprintf("func %v(arity int, p *%v.Context) {\n", v.qExecName(), f.Import(qlang_lib, qlang_def))
+		decl += "\targs := p.GetArgs(arity)\n"
+	} else {
+		decl += fmt.Sprintf("func %v(_ int, p *%v.Context) {\n", v.qExecName(), f.Import(qlang_lib, qlang_def))
+		decl += fmt.Sprintf("\targs := p.GetArgs(%v)\n", argLen)
+	}
+	f.runtime("qconv")
+	f.runtime("qargError")
+	if v.recv != nil {
+		// the method value of the converted receiver
+		decl += fmt.Sprintf("\tret := %v(%v.ValueOf(%v), %q, 1, args[1:])\n", f.runtime("qcall"), f.Import("reflect", "reflect"), v.callExpr(f), v.CallName())
+	} else {
+		decl += fmt.Sprintf("\tret := %v(%v.ValueOf(%v), %q, 0, args)\n", f.runtime("qcall"), f.Import("reflect", "reflect"), v.callName(f.Qualifier), v.CallName())
+	}
+	if v.Variadic() {
+		decl += "\tp.Ret(arity, ret...)\n"
+	} else {
+		decl += fmt.Sprintf("\tp.Ret(%v, ret...)\n", argLen)
+	}
+	decl += "}"
+	return decl, nil
+}
+
+// ExportDecl returns the exec function of the function, empty for the value
+// methods registered for *recv which use the exec function of recv.
+func (v *GoFunc) ExportDecl(f *File) (string, error) {
+	if v.shared {
+		return "", nil
+	}
+	if v.reflective {
+		return v.exportDeclR(f)
+	}
 	if v.Variadic() {
-		return v.exportDeclV()
+		return v.exportDeclV(f)
 	}
 	var decl string
 	argLen := v.Signature().Params().Len()
@@ -258,8 +358,8 @@ func (v *GoFunc) ExportDecl() (string, error) {
 		argBase = 1
 	}
 
-	decl += fmt.Sprintf("// %v\n", simpleObjInfo(v.obj))
-	decl += fmt.Sprintf("func %v(_ int, p *%v.Context) {\n", v.qExecName(), qlang)
+	decl += fmt.Sprintf("// %v\n", v.objInfo())
+	decl += fmt.Sprintf("func %v(_ int, p *%v.Context) {\n", v.qExecName(), f.Import(qlang_lib, qlang_def))
 	if argLen != 0 {
 		decl += fmt.Sprintf("\targs := p.GetArgs(%v)\n", argLen)
 	}
@@ -271,8 +371,6 @@ func (v *GoFunc) ExportDecl() (string, error) {
 	}
 	for i := 0; i < v.Signature().Params().Len(); i++ {
 		iv := v.Signature().Params().At(i)
-		it := simpleType(iv.Type().String())
-		var basic string
 		// switch vt := iv.Type().(type) {
 		// case *types.Basic:
 		// 	basic = vt.String()
@@ -284,24 +382,13 @@ func (v *GoFunc) ExportDecl() (string, error) {
 		// 	if vt.Obj().Type().Underlying()
 		// default:
 		// }
-		switch vt := iv.Type().Underlying().(type) {
-		case *types.Basic:
-			basic = vt.String()
-		}
-		if basic != "" && basic != it {
-			paramList = append(paramList, fmt.Sprintf("%v(args[%v].(%v))", it, argBase+i, basic))
-		} else {
-			paramList = append(paramList, fmt.Sprintf("args[%v].(%v)", argBase+i, it))
-		}
+		paramList = append(paramList, argExpr(f, v.CallName(), fmt.Sprintf("args[%v]", argBase+i), strconv.Itoa(argBase+i), iv.Type()))
 	}
 	decl += "\t"
 	if retLen > 0 {
 		decl += strings.Join(retList, ",") + " := "
 	}
-	if v.recv != nil {
-		decl += "args[0]."
-	}
-	decl += v.CallName() + "(" + strings.Join(paramList, ", ") + ")\n"
+	decl += v.callExpr(f) + "(" + strings.Join(paramList, ", ") + ")\n"
 	if retLen > 0 {
 		decl += fmt.Sprintf("\tp.Ret(%v, %v)\n", argLen, strings.Join(retList, ","))
 	}
@@ -309,18 +396,126 @@ func (v *GoFunc) ExportDecl() (string, error) {
 	return decl, nil
 }
 
+// GoField is the getter or setter of an exported struct field.
+type GoField struct {
+	GoObject
+	typ   *types.Var
+	recv  *types.Named
+	rname string // Go+ name of recv
+	set   bool
+}
+
+func (p *GoField) Name() string {
+	return p.rname + "." + p.id.Name
+}
+
+func (p *GoField) qRegName() string {
+	name := "(*" + p.rname + ")." + p.id.Name
+	if p.set {
+		name += "="
+	}
+	return name
+}
+
+func (p *GoField) qExecName() string {
+	if p.set {
+		return "execset" + p.rname + p.id.Name
+	}
+	return "execget" + p.rname + p.id.Name
+}
+
+func (p *GoField) recvType(qf types.Qualifier) string {
+	return types.TypeString(types.NewPointer(p.recv), qf)
+}
+
+func (p *GoField) recvExpr(f *File) string {
+	return argExpr(f, p.CallName(), "args[0]", "0", types.NewPointer(p.recv))
+}
+
+func (p *GoField) CallName() string {
+	return "(" + p.recvType(pkgName) + ")." + p.id.Name
+}
+
+// func(v *T) F / func(v *T, x F)
+func (p *GoField) funcLit(f *File) string {
+	ft := f.TypeString(p.typ.Type())
+	if p.set {
+		return fmt.Sprintf("func(v %v, x %v) { v.%v = x }", p.recvType(f.Qualifier), ft, p.id.Name)
+	}
+	return fmt.Sprintf("func(v %v) %v { return v.%v }", p.recvType(f.Qualifier), ft, p.id.Name)
+}
+
+// Check reports an error if the type of the field cannot be named by the
+// generated code.
+func (p *GoField) Check() error {
+	return checkAccess(p.typ.Type())
+}
+
+func (p *GoField) ExportRegister(f *File) (string, error) {
+	return fmt.Sprintf("I.Func(%q, %v, %v)", p.qRegName(), p.funcLit(f), p.qExecName()), nil
+}
+
+func (p *GoField) ExportDecl(f *File) (string, error) {
+	var decl string
+	ft := types.TypeString(p.typ.Type(), pkgName)
+	if p.set {
+		decl += fmt.Sprintf("// set field %v %v\n", p.CallName(), ft)
+		decl += fmt.Sprintf("func %v(_ int, p *%v.Context) {\n", p.qExecName(), f.Import(qlang_lib, qlang_def))
+		decl += "\targs := p.GetArgs(2)\n"
+		decl += fmt.Sprintf("\t%v.%v = %v\n", p.recvExpr(f), p.id.Name, argExpr(f, p.CallName(), "args[1]", "1", p.typ.Type()))
+	} else {
+		decl += fmt.Sprintf("// get field %v %v\n", p.CallName(), ft)
+		decl += fmt.Sprintf("func %v(_ int, p *%v.Context) {\n", p.qExecName(), f.Import(qlang_lib, qlang_def))
+		decl += "\targs := p.GetArgs(1)\n"
+		decl += fmt.Sprintf("\tret := %v.%v\n", p.recvExpr(f), p.id.Name)
+		decl += "\tp.Ret(1, ret)\n"
+	}
+	decl += "}"
+	return decl, nil
+}
+
 type GoType struct {
 	GoObject
 	typ *types.TypeName
+
+	// instantiation of a generic type, id is the Go+ name.
+	inst  *types.Named
+	targs []types.Type
 }
 
-func (v *GoType) ExportRegister() (string, error) {
-	kind, err := v.toQlangKind()
+// Type returns the type, instantiated for generic types.
+func (v *GoType) Type() types.Type {
+	if v.inst != nil {
+		return v.inst
+	}
+	return v.typ.Type()
+}
+
+// typeExpr returns the type expression of the type, qualified by f.
+func (v *GoType) typeExpr(f *File) string {
+	return f.ObjName(v.obj) + typeArgs(v.targs, f.Qualifier)
+}
+
+// Check reports an error if the type, or the aliased type, cannot be named by
+// the generated code.
+func (v *GoType) Check() error {
+	if named, ok := v.Type().(*types.Named); ok && !v.typ.IsAlias() {
+		if tparams := named.TypeParams(); tparams.Len() > 0 && named.TypeArgs().Len() == 0 {
+			return fmt.Errorf("generic type is not instantiated by the config (%w %v)", errTypeParam, tparams.At(0))
+		}
+	}
+	return checkAccess(v.Type())
+}
+
+func (v *GoType) ExportRegister(f *File) (string, error) {
+	kind, err := v.toQlangKind(f)
 	if err != nil {
 		return "", err
 	}
+	// instances are registered by their Go+ name, the reflect name of
+	// List[int] is not an identifier.
 	var item string
-	if strings.HasPrefix(kind, qspec+".") {
+	if _, ok := v.Type().Underlying().(*types.Basic); ok || v.inst != nil {
 		item = fmt.Sprintf("I.Type(%q, %v)", v.id.Name, kind)
 	} else {
 		item = fmt.Sprintf("I.Rtype(%v)", kind)
@@ -329,20 +524,30 @@ func (v *GoType) ExportRegister() (string, error) {
 }
 
 type GoPkg struct {
-	Pkg    *packages.Package
-	Consts []*GoConst
-	Vars   []*GoVar
-	Funcs  []*GoFunc
-	Types  []*GoType
+	Pkg     *packages.Package
+	Consts  []*GoConst
+	Vars    []*GoVar
+	Funcs   []*GoFunc
+	Types   []*GoType
+	Fields  []*GoField
+	Proxies []*GoProxy
+
+	ctx     *build.Context
+	imports map[string]*types.Package // packages of the type arguments
 }
 
-func LoadGoPkg(pkg string) (*GoPkg, error) {
+// LoadGoPkg loads pkg for the os, arch, cgo and build tags of the context ctx,
+// or for the default context of the go command if ctx is nil.
+func LoadGoPkg(pkg string, ctx *build.Context) (*GoPkg, error) {
 	cfg := &packages.Config{Mode: packages.NeedFiles |
 		packages.NeedSyntax |
 		packages.NeedTypesInfo |
 		packages.NeedTypes}
-	if pkg == "syscall/js" {
-		cfg.Env = append(os.Environ(), "GOOS=js", "GOARCH=wasm")
+	if ctx != nil {
+		cfg.Env = contextEnv(ctx)
+		if len(ctx.BuildTags) > 0 {
+			cfg.BuildFlags = []string{"-tags=" + strings.Join(ctx.BuildTags, ",")}
+		}
 	}
 	pkgs, err := packages.Load(cfg, pkg)
 	if err != nil {
@@ -351,7 +556,27 @@ func LoadGoPkg(pkg string) (*GoPkg, error) {
 	if len(pkgs) < 1 {
 		return nil, fmt.Errorf("error load pkg %v", pkg)
 	}
-	return &GoPkg{Pkg: pkgs[0]}, nil
+	if len(pkgs[0].Errors) > 0 {
+		return nil, pkgs[0].Errors[0]
+	}
+	return &GoPkg{Pkg: pkgs[0], ctx: ctx}, nil
+}
+
+// Import loads the package path for the context of p, the type arguments of
+// the instances name packages p does not import.
+func (p *GoPkg) Import(path string) (*types.Package, error) {
+	if pkg, ok := p.imports[path]; ok {
+		return pkg, nil
+	}
+	dep, err := LoadGoPkg(path, p.ctx)
+	if err != nil {
+		return nil, err
+	}
+	if p.imports == nil {
+		p.imports = make(map[string]*types.Package)
+	}
+	p.imports[path] = dep.Pkg.Types
+	return dep.Pkg.Types, nil
 }
 
 func (p *GoPkg) checkTypeName(ident *ast.Ident, obj types.Object, underlying types.Type) {
@@ -394,22 +619,20 @@ func (p *GoPkg) Sort() {
 		return p.Types[i].Name() < p.Types[j].Name()
 	})
 	sort.Slice(p.Funcs, func(i, j int) bool {
+		if p.Funcs[i].Name() == p.Funcs[j].Name() {
+			return !p.Funcs[i].ptr && p.Funcs[j].ptr
+		}
 		return p.Funcs[i].Name() < p.Funcs[j].Name()
 	})
-}
-
-func funcRecvType(ident *ast.Ident, typ types.Type) *types.Named {
-	switch t := typ.(type) {
-	case *types.Pointer:
-		return funcRecvType(ident, t.Elem())
-	case *types.Named:
-		return t
-	case *types.Interface:
-		return nil
-	default:
-		log.Fatalf("uncheck funcRecvType %v %v\n", ident, t)
-	}
-	return nil
+	sort.Slice(p.Proxies, func(i, j int) bool {
+		return p.Proxies[i].Name() < p.Proxies[j].Name()
+	})
+	sort.Slice(p.Fields, func(i, j int) bool {
+		if p.Fields[i].Name() == p.Fields[j].Name() {
+			return !p.Fields[i].set
+		}
+		return p.Fields[i].Name() < p.Fields[j].Name()
+	})
 }
 
 func (p *GoPkg) LoadAll(exported bool) error {
@@ -417,48 +640,201 @@ func (p *GoPkg) LoadAll(exported bool) error {
 		if obj == nil || !ident.IsExported() {
 			continue
 		}
-		if obj.Parent() == p.Pkg.Types.Scope() {
-			switch typ := obj.(type) {
-			case *types.Const:
-				p.Consts = append(p.Consts, &GoConst{GoObject{ident, obj}, typ})
-			case *types.Var:
-				p.Vars = append(p.Vars, &GoVar{GoObject{ident, obj}, typ})
-			case *types.Func:
-				p.Funcs = append(p.Funcs, &GoFunc{GoObject{ident, obj}, typ, nil})
-			case *types.TypeName:
-				//log.Printf("%v  %T IsAlias: %v\n", obj, obj.Type(), obj.(*types.TypeName).IsAlias())
-				p.Types = append(p.Types, &GoType{GoObject{ident, obj}, typ})
-			case *types.Label:
-				// skip
-			case *types.PkgName:
+		if obj.Parent() != p.Pkg.Types.Scope() {
+			continue
+		}
+		switch typ := obj.(type) {
+		case *types.Const:
+			p.Consts = append(p.Consts, &GoConst{GoObject{ident, obj}, typ})
+		case *types.Var:
+			p.Vars = append(p.Vars, &GoVar{GoObject{ident, obj}, typ})
+		case *types.Func:
+			p.Funcs = append(p.Funcs, &GoFunc{GoObject: GoObject{ident, obj}, typ: typ})
+		case *types.TypeName:
+			//log.Printf("%v  %T IsAlias: %v\n", obj, obj.Type(), obj.(*types.TypeName).IsAlias())
+			p.Types = append(p.Types, &GoType{GoObject: GoObject{ident, obj}, typ: typ})
+			if named, ok := typ.Type().(*types.Named); ok && !typ.IsAlias() && named.TypeParams().Len() == 0 {
+				p.loadNamed(ident, named)
+			}
+		case *types.Label:
 			// skip
-			default:
-				log.Printf("warring, uncheck %v %T, %v \n", ident, typ, p.Pkg.Fset.Position(ident.Pos()))
+		case *types.PkgName:
+		// skip
+		default:
+			log.Printf("warring, uncheck %v %T, %v \n", ident, typ, p.Pkg.Fset.Position(ident.Pos()))
+		}
+	}
+	return nil
+}
+
+// loadNamed adds the methods, fields and interface proxy of the named type
+// whose Go+ name is ident.
+func (p *GoPkg) loadNamed(ident *ast.Ident, named *types.Named) {
+	p.loadMethods(named, ident.Name)
+	p.loadFields(named, ident.Name)
+	if _, ok := named.Underlying().(*types.Interface); ok {
+		p.Proxies = append(p.Proxies, &GoProxy{GoObject{ident, named.Obj()}, named})
+	}
+}
+
+// LoadInstances adds the configured instantiations of the generic functions
+// and types of the package.
+func (p *GoPkg) LoadInstances(list []*Instance) {
+	for _, inst := range list {
+		if inst.Path != p.Pkg.Types.Path() {
+			continue
+		}
+		if err := p.loadInstance(inst); err != nil {
+			log.Printf("warning, skip instance %v, %v\n", inst, err)
+		}
+	}
+}
+
+func (p *GoPkg) loadInstance(inst *Instance) error {
+	obj := p.Pkg.Types.Scope().Lookup(inst.Sym)
+	if obj == nil || !obj.Exported() {
+		return fmt.Errorf("%v is not exported", inst.Sym)
+	}
+	targs, err := resolveTypes(p.Pkg.Types, p, inst.TArgs)
+	if err != nil {
+		return err
+	}
+	// the type arguments are not inferred, slices.Sort[[]float64] is
+	// slices.Sort[[]float64, float64].
+	if n := typeParams(obj); n > 0 && n != len(targs) {
+		return fmt.Errorf("%v has %v type parameters, got %v type arguments", inst.Sym, n, len(targs))
+	}
+	name := inst.Name
+	if name == "" {
+		name = instanceName(inst.Sym, targs)
+	}
+	switch obj := obj.(type) {
+	case *types.Func:
+		sig := obj.Type().(*types.Signature)
+		if sig.TypeParams().Len() == 0 {
+			return fmt.Errorf("%v is not generic", inst.Sym)
+		}
+		typ, err := types.Instantiate(nil, sig, targs, true)
+		if err != nil {
+			return err
+		}
+		for _, v := range p.Funcs {
+			if v.recv == nil && v.id.Name == name {
+				return fmt.Errorf("duplicate name %v", name)
 			}
-		} else {
-			if typ, ok := obj.(*types.Func); ok {
-				sig, ok := typ.Type().Underlying().(*types.Signature)
-				if ok && sig.Recv() != nil {
-					named := funcRecvType(ident, sig.Recv().Type())
-					if named != nil && named.Obj().Exported() && named.Obj().Parent() == p.Pkg.Types.Scope() {
-						switch nt := named.Underlying().(type) {
-						case *types.Struct:
-							p.Funcs = append(p.Funcs, &GoFunc{GoObject{ident, obj}, typ, named})
-						case *types.Basic, *types.Slice, *types.Map, *types.Signature:
-							p.Funcs = append(p.Funcs, &GoFunc{GoObject{ident, obj}, typ, named})
-						case *types.Interface:
-							// TODO skip interface
-						default:
-							log.Fatalf("uncheck types.Func %v %v %T\n", ident, obj, nt)
-						}
-					}
-				}
+		}
+		p.Funcs = append(p.Funcs, &GoFunc{GoObject: GoObject{ast.NewIdent(name), obj}, typ: obj, sig: typ.(*types.Signature), targs: targs})
+	case *types.TypeName:
+		named, ok := obj.Type().(*types.Named)
+		if !ok || obj.IsAlias() || named.TypeParams().Len() == 0 {
+			return fmt.Errorf("%v is not generic", inst.Sym)
+		}
+		typ, err := types.Instantiate(nil, named, targs, true)
+		if err != nil {
+			return err
+		}
+		for _, v := range p.Types {
+			if v.id.Name == name {
+				return fmt.Errorf("duplicate name %v", name)
 			}
 		}
+		ident := ast.NewIdent(name)
+		p.Types = append(p.Types, &GoType{GoObject: GoObject{ident, obj}, typ: obj, inst: typ.(*types.Named), targs: targs})
+		p.loadNamed(ident, typ.(*types.Named))
+	default:
+		return fmt.Errorf("%v is not a function or type", inst.Sym)
 	}
 	return nil
 }
 
+// typeParams returns the number of type parameters of the generic function
+// or type obj.
+func typeParams(obj types.Object) int {
+	switch typ := obj.Type().(type) {
+	case *types.Signature:
+		return typ.TypeParams().Len()
+	case *types.Named:
+		return typ.TypeParams().Len()
+	}
+	return 0
+}
+
+// loadMethods adds the exported methods in the method sets of named and
+// *named, including the methods promoted from embedded fields. The value
+// methods are added for both named and *named.
+func (p *GoPkg) loadMethods(named *types.Named, name string) {
+	add := func(mset *types.MethodSet, ptr bool) {
+		for i := 0; i < mset.Len(); i++ {
+			fn := mset.At(i).Obj().(*types.Func)
+			if !fn.Exported() {
+				continue
+			}
+			if ptr && types.NewMethodSet(named).Lookup(fn.Pkg(), fn.Name()) != nil {
+				continue
+			}
+			p.Funcs = append(p.Funcs, &GoFunc{GoObject: GoObject{ast.NewIdent(fn.Name()), fn}, typ: fn, recv: named, rname: name, ptr: ptr})
+			if _, ok := named.Underlying().(*types.Interface); !ok && !ptr {
+				p.Funcs = append(p.Funcs, &GoFunc{GoObject: GoObject{ast.NewIdent(fn.Name()), fn}, typ: fn, recv: named, rname: name, ptr: true, shared: true})
+			}
+		}
+	}
+	add(types.NewMethodSet(named), false)
+	if _, ok := named.Underlying().(*types.Interface); !ok {
+		add(types.NewMethodSet(types.NewPointer(named)), true)
+	}
+}
+
+// loadFields adds a getter and a setter for the exported fields of the
+// struct named, including the fields promoted from embedded structs.
+func (p *GoPkg) loadFields(named *types.Named, name string) {
+	if _, ok := named.Underlying().(*types.Struct); !ok {
+		return
+	}
+	// collect the field names of every embedding depth, LookupFieldOrMethod
+	// resolves which of them are selectable.
+	var names []string
+	seen := make(map[string]bool)
+	visited := make(map[*types.Named]bool)
+	var walk func(typ types.Type)
+	walk = func(typ types.Type) {
+		if ptr, ok := typ.(*types.Pointer); ok {
+			typ = ptr.Elem()
+		}
+		if n, ok := typ.(*types.Named); ok {
+			if visited[n] {
+				return
+			}
+			visited[n] = true
+		}
+		st, ok := typ.Underlying().(*types.Struct)
+		if !ok {
+			return
+		}
+		for i := 0; i < st.NumFields(); i++ {
+			f := st.Field(i)
+			if f.Exported() && !seen[f.Name()] {
+				seen[f.Name()] = true
+				names = append(names, f.Name())
+			}
+			if f.Embedded() {
+				walk(f.Type())
+			}
+		}
+	}
+	walk(named)
+	for _, fname := range names {
+		obj, _, _ := types.LookupFieldOrMethod(named, true, p.Pkg.Types, fname)
+		field, ok := obj.(*types.Var)
+		if !ok || !field.IsField() {
+			continue
+		}
+		id := ast.NewIdent(fname)
+		p.Fields = append(p.Fields,
+			&GoField{GoObject{id, field}, field, named, name, false},
+			&GoField{GoObject{id, field}, field, named, name, true})
+	}
+}
+
 /*
 	ConstBoundRune = spec.ConstBoundRune
 	// ConstBoundString - bound type: string
@@ -470,34 +846,36 @@ func (p *GoPkg) LoadAll(exported bool) error {
 	// ConstUnboundComplex - unbound complex type
 	ConstUnboundComplex = spec.ConstUnboundComplex
 */
-func (p *GoConst) toQlangKind(pkg string) (string, error) {
+func (p *GoConst) toQlangKind(f *File) (string, error) {
 	baisc, ok := p.typ.Type().Underlying().(*types.Basic)
 	if !ok {
 		return "", fmt.Errorf("un basic of const %v %v", p.id, p.typ)
 	}
+	pkg := func() string { return f.Import(qspec_lib, qspec_def) }
+	reflect := func() string { return f.Import("reflect", "reflect") }
 	switch baisc.Kind() {
 	case types.UntypedBool:
-		return "reflect.Bool", nil
+		return reflect() + ".Bool", nil
 	case types.UntypedInt:
-		return pkg + ".ConstUnboundInt", nil
+		return pkg() + ".ConstUnboundInt", nil
 	case types.UntypedRune:
-		return pkg + ".ConstBoundRune", nil
+		return pkg() + ".ConstBoundRune", nil
 	case types.UntypedFloat:
-		return pkg + ".ConstUnboundFloat", nil
+		return pkg() + ".ConstUnboundFloat", nil
 	case types.UntypedComplex:
-		return pkg + ".ConstUnboundComplex", nil
+		return pkg() + ".ConstUnboundComplex", nil
 	case types.UntypedString:
-		return pkg + ".ConstBoundString", nil
+		return pkg() + ".ConstBoundString", nil
 	case types.UntypedNil:
-		return pkg + ".ConstUnboundPtr", nil
+		return pkg() + ".ConstUnboundPtr", nil
 	case types.Byte:
-		return "reflect.Uint8", nil
+		return reflect() + ".Uint8", nil
 	case types.Rune:
-		return "reflect.Uint32", nil
+		return reflect() + ".Uint32", nil
 	}
 
 	// TODO
-	return "reflect." + strings.Title(baisc.Name()), nil
+	return reflect() + "." + strings.Title(baisc.Name()), nil
 
 	// switch p.typ.Val().Kind() {
 	// case constant.Bool:
@@ -518,20 +896,53 @@ func (p *GoConst) toQlangKind(pkg string) (string, error) {
 func (v *GoConst) Name() string {
 	return v.id.Name
 }
-func (v *GoConst) ExportRegister() (string, error) {
-	kind, err := v.toQlangKind(qspec)
+
+// Untyped reports whether the constant is untyped.
+func (v *GoConst) Untyped() bool {
+	basic, ok := v.typ.Type().(*types.Basic)
+	return ok && basic.Info()&types.IsUntyped != 0
+}
+
+func (v *GoConst) ExportRegister(f *File) (string, error) {
+	kind, err := v.toQlangKind(f)
 	if err != nil {
 		return "", err
 	}
-	if v.typ.Val().Kind() == constant.Int {
-		ck := checkConstType(v.typ.Val().String())
-		if ck == ConstInt64 {
-			return fmt.Sprintf("I.Const(%q, %v.Int64, int64(%v))", v.id.Name, qspec, v.FullName()), nil
-		} else if ck == ConstUnit64 {
-			return fmt.Sprintf("I.Const(%q, %v.Uint64, uint64(%v))", v.id.Name, qspec, v.FullName()), nil
+	if v.Untyped() {
+		return fmt.Sprintf("I.Const(%q, %v, %v)", v.id.Name, kind, v.valueExpr(f)), nil
+	}
+	// I.Const takes the kind only, the named type of a typed constant, the
+	// time.Duration of time.Second, cannot be registered.
+	return fmt.Sprintf("I.Const(%q, %v, %v)", v.id.Name, kind, f.ObjName(v.obj)), nil
+}
+
+// valueExpr returns the exact value of the untyped constant. Integers out of
+// the int32 range are *big.Int and floats not exact in float64 are *big.Rat,
+// other values are the constant itself.
+func (v *GoConst) valueExpr(f *File) string {
+	val := v.typ.Val()
+	switch val.Kind() {
+	case constant.Int:
+		if n, ok := constant.Int64Val(val); ok && n >= math.MinInt32 && n <= math.MaxInt32 {
+			break
+		}
+		return fmt.Sprintf("%v(%q)", f.runtime("qbigInt"), val.ExactString())
+	case constant.Float:
+		if _, exact := constant.Float64Val(val); exact {
+			break
+		}
+		var r *big.Rat
+		switch x := constant.Val(val).(type) {
+		case *big.Rat:
+			r = x
+		case *big.Float:
+			r, _ = x.Rat(nil)
+		}
+		if r != nil {
+			return fmt.Sprintf("%v(%q)", f.runtime("qbigRat"), r.String())
 		}
 	}
-	return fmt.Sprintf("I.Const(%q, %v, %v)", v.id.Name, kind, v.FullName()), nil
+	return f.ObjName(v.obj)
 }
 
 func typesBasicToQlang(pkg string, typ *types.Basic) string {
@@ -578,30 +989,31 @@ func typesBasicToQlang(pkg string, typ *types.Basic) string {
 	return ""
 }
 
-func (p *GoType) typeNameToQlangKind() string {
-	switch typ := p.typ.Type().Underlying().(type) {
+func (p *GoType) typeNameToQlangKind(f *File) string {
+	switch typ := p.Type().Underlying().(type) {
 	case *types.Struct:
-		return fmt.Sprintf("reflect.TypeOf((*%v)(nil))", p.FullName())
+		return fmt.Sprintf("%v.TypeOf((*%v)(nil))", f.Import("reflect", "reflect"), p.typeExpr(f))
 	case *types.Interface:
-		// TODO
+		return fmt.Sprintf("%v.TypeOf((*%v)(nil)).Elem()", f.Import("reflect", "reflect"), p.typeExpr(f))
 	case *types.Basic:
-		return typesBasicToQlang(qspec, typ)
-	case *types.Signature:
-	case *types.Slice:
-	case *types.Array:
-	case *types.Map:
-	case *types.Chan:
-	case *types.Pointer:
+		if _, ok := p.Type().(interface{ Obj() *types.TypeName }); ok {
+			// keep the named type and its methods, time.Duration is not
+			// int64. os.FileMode is an alias of the named fs.FileMode.
+			return fmt.Sprintf("%v.TypeOf((*%v)(nil)).Elem()", f.Import("reflect", "reflect"), p.typeExpr(f))
+		}
+		return typesBasicToQlang(f.Import(qspec_lib, qspec_def), typ)
+	case *types.Signature, *types.Slice, *types.Array, *types.Map, *types.Chan, *types.Pointer:
+		return fmt.Sprintf("%v.TypeOf((*%v)(nil)).Elem()", f.Import("reflect", "reflect"), p.typeExpr(f))
 	default:
 		log.Printf("unparse GoTypes typ %v %T\n", typ, typ)
 	}
 	return ""
 }
 
-func (p *GoType) toQlangKind() (string, error) {
-	kind := p.typeNameToQlangKind()
+func (p *GoType) toQlangKind(f *File) (string, error) {
+	kind := p.typeNameToQlangKind(f)
 	if kind != "" {
 		return kind, nil
 	}
-	return "", fmt.Errorf("unparser type %v %T", p.id, p.typ.Type().Underlying())
+	return "", fmt.Errorf("unparser type %v %T", p.id, p.Type().Underlying())
 }
@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"go/build"
 	"log"
 	"os"
 	"os/exec"
@@ -21,6 +22,9 @@ var (
 	flagUpdatePath               string
 	flagFilterList               string
 	flagBuildTags                string
+	flagConfig                   string
+	flagGoVersion                string
+	flagSplit                    int
 )
 
 const help = `Export Go packages to Go+ modules.
@@ -32,22 +36,27 @@ The packages for go package list or std for golang all standard packages.
 `
 
 func usage() {
-	fmt.Fprintln(os.Stderr, help)
+	fmt.Fprint(os.Stderr, help+"\n")
 	flag.PrintDefaults()
 }
 
 func init() {
-	// flag.StringVar(&flagCustomContext, "contexts", "", "optional comma-separated list of <goos>-<goarch>[-cgo] to override default contexts.")
-	// flag.BoolVar(&flagDefaultContext, "defctx", false, "optional use default context for build, default use all contexts.")
+	flag.StringVar(&flagCustomContext, "contexts", "", "optional comma-separated list of <goos>-<goarch>[-cgo] to override default contexts.")
+	flag.BoolVar(&flagDefaultContext, "defctx", false, "optional use default context for build, default use all contexts.")
 	//flag.BoolVar(&flagSkipErrorImplementStruct, "skiperrimpl", true, "optional skip error interface implement struct.")
 	flag.StringVar(&flagExportPath, "outdir", "./lib", "optional set export output root path")
 	flag.StringVar(&flagFilterList, "filter", "", "optional set export filter regular expression list, separated by spaces.")
-	//flag.StringVar(&flagBuildTags, "tags", "", "optional a comma-separated list of build tags to consider satisfied during the build. ")
+	flag.IntVar(&flagSplit, "split", 0, "optional split the export of a package into files by kind of at most split symbols each, 0 for a single exports.go.")
+	flag.StringVar(&flagConfig, "config", "", "optional set config file of generic instantiations, one [name =] pkg.Sym[T1, T2] per line.")
+	flag.StringVar(&flagGoVersion, "goversion", "", "optional set the target Go version go1.N, the symbols added after it are not exported.")
+	flag.StringVar(&flagBuildTags, "tags", "", "optional a comma-separated list of build tags to consider satisfied during the build. ")
 }
 
 var (
-	ac     *ApiCheck
-	reList []*regexp.Regexp
+	ac        *ApiCheck
+	reList    []*regexp.Regexp
+	instances []*Instance
+	buildTags []string
 )
 
 func main() {
@@ -62,7 +71,31 @@ func main() {
 	if flagCustomContext != "" {
 		flagDefaultContext = false
 		setCustomContexts(flagCustomContext)
+	} else if flagDefaultContext {
+		ctx := build.Default
+		contexts = []*build.Context{&ctx}
+	} else {
+		// cgo needs a C cross compiler, the default contexts of the other
+		// platforms are loaded without it.
+		for _, c := range contexts {
+			if c.GOOS != build.Default.GOOS || c.GOARCH != build.Default.GOARCH {
+				c.CgoEnabled = false
+			}
+		}
+	}
+	buildTags = strings.FieldsFunc(flagBuildTags, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+	var ctxs []*build.Context
+	for _, c := range contexts {
+		if !supportedContext(c) {
+			log.Printf("warning, skip context %v, unsupported by the go command\n", contextName(c))
+			continue
+		}
+		c.BuildTags = buildTags
+		ctxs = append(ctxs, c)
 	}
+	contexts = ctxs
 	if flagFilterList != "" {
 		for _, expr := range strings.Split(flagFilterList, " ") {
 			re, err := regexp.Compile(expr)
@@ -73,13 +106,44 @@ func main() {
 		}
 	}
 
-	//load ApiCheck
+	if flagConfig != "" {
+		list, err := LoadConfig(flagConfig)
+		if err != nil {
+			log.Fatalln("config error", err)
+		}
+		instances = list
+	}
+
+	//load ApiCheck, the versions after the target, or after go1, are loaded as
+	//apis
+	vers, err := FindApiFiles()
+	if err != nil {
+		if flagGoVersion != "" {
+			log.Fatalln("api files error", err)
+		}
+		log.Println("warning, api files", err)
+	}
+	target := apiVersion("go1")
+	if flagGoVersion != "" {
+		target = apiVersion(flagGoVersion)
+		if target < 0 {
+			log.Fatalln("bad goversion", flagGoVersion)
+		}
+	}
+	var base, apis []*ApiFiles
+	for _, v := range vers {
+		if apiVersion(v.Ver) <= target {
+			base = append(base, v)
+		} else {
+			apis = append(apis, v)
+		}
+	}
 	ac = NewApiCheck()
-	err := ac.LoadBase("go1", "go1.1", "go1.2", "go1.3", "go1.4", "go1.5", "go1.6", "go1.7", "go1.8", "go1.9", "go1.10", "go1.12", "go1.13")
+	err = ac.LoadBase(base...)
 	if err != nil {
 		log.Println(err)
 	}
-	err = ac.LoadApi("go1.14")
+	err = ac.LoadApi(apis...)
 	if err != nil {
 		log.Println(err)
 	}
@@ -110,7 +174,7 @@ func main() {
 		if isSkipPkg(pkg) {
 			continue
 		}
-		err := export(pkg, outpath, flagBuildTags)
+		err := export(pkg, outpath, strings.Join(buildTags, ","))
 		if err != nil {
 			log.Printf("warning skip pkg %q, error %v.\n", pkg, err)
 		} else {
@@ -120,6 +184,11 @@ func main() {
 	for _, pkg := range exportd {
 		log.Printf("export pkg %q success.\n", pkg)
 	}
+	for _, pkg := range exportd {
+		if list := excluded[pkg]; len(list) > 0 {
+			log.Printf("export pkg %q excluded %v symbols added after %v:\n\t%v\n", pkg, len(list), flagGoVersion, strings.Join(list, "\n\t"))
+		}
+	}
 }
 
 func filterSym(sym string) bool {
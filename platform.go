@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"go/build"
+	"log"
+	"strings"
+)
+
+// platform is a package loaded for a context.
+type platform struct {
+	ctx *build.Context
+	pkg *GoPkg
+}
+
+// loadPlatforms loads pkg for each of the contexts, the contexts pkg cannot be
+// loaded for are skipped. A std package the api files list the same for all
+// the contexts is loaded for the first one only. restricted reports whether
+// the package is loaded for a single context or not for all of them, the
+// generated files must be constrained to the loaded ones.
+func loadPlatforms(pkg string) (list []*platform, restricted bool, err error) {
+	ctxs := contexts
+	if pkg == "syscall/js" {
+		ctxs = []*build.Context{{GOOS: "js", GOARCH: "wasm", BuildTags: buildTags}}
+	}
+	single := ac.Pkgs[pkg] && !ac.PlatformPkg(pkg)
+	for _, ctx := range ctxs {
+		if single && len(list) > 0 {
+			break
+		}
+		p, err := LoadGoPkg(pkg, ctx)
+		if err != nil {
+			if len(ctxs) == 1 {
+				return nil, false, err
+			}
+			log.Printf("warning, skip context %v of pkg %v, %v\n", contextName(ctx), pkg, strings.TrimSpace(err.Error()))
+			continue
+		}
+		p.LoadAll(true)
+		p.LoadInstances(instances)
+		p.Sort()
+		list = append(list, &platform{ctx: ctx, pkg: p})
+	}
+	if len(list) == 0 {
+		return nil, false, fmt.Errorf("no context to load pkg %v", pkg)
+	}
+	if len(ctxs) == 1 || !single && len(list) < len(ctxs) {
+		restricted = true
+	}
+	return list, restricted, nil
+}
+
+// commonKeys returns the keys of the symbols of the platforms found in all
+// of them with the same type.
+func commonKeys(list []*platform) map[string]bool {
+	count := make(map[string]int)
+	for _, v := range list {
+		for _, key := range v.pkg.symKeys() {
+			count[key]++
+		}
+	}
+	common := make(map[string]bool)
+	for key, n := range count {
+		if n == len(list) {
+			common[key] = true
+		}
+	}
+	return common
+}
+
+// symKeys returns the keys of the symbols of the package.
+func (p *GoPkg) symKeys() []string {
+	var keys []string
+	for _, v := range p.Consts {
+		keys = append(keys, symKey(v))
+	}
+	for _, v := range p.Vars {
+		keys = append(keys, symKey(v))
+	}
+	for _, v := range p.Types {
+		keys = append(keys, symKey(v))
+	}
+	for _, v := range p.Proxies {
+		keys = append(keys, symKey(v))
+	}
+	for _, v := range p.Funcs {
+		keys = append(keys, symKey(v))
+	}
+	for _, v := range p.Fields {
+		keys = append(keys, symKey(v))
+	}
+	return keys
+}
+
+// symKey returns the key comparing a symbol across platforms, the kind and
+// name of the symbol and the types its generated code depends on.
+func symKey(sym interface{}) string {
+	switch v := sym.(type) {
+	case *GoConst:
+		return fmt.Sprintf("const %v %v %v", v.Name(), typeKey(v.typ.Type()), v.typ.Val().ExactString())
+	case *GoVar:
+		return fmt.Sprintf("var %v %v", v.Name(), typeKey(v.typ.Type()))
+	case *GoType:
+		return fmt.Sprintf("type %v %v %v", v.Name(), typeKey(v.Type()), typeKey(v.Type().Underlying()))
+	case *GoProxy:
+		return fmt.Sprintf("proxy %v %v", v.Name(), typeKey(v.named.Underlying()))
+	case *GoFunc:
+		return fmt.Sprintf("func %v %v", v.qRegName(), typeKey(v.Signature()))
+	case *GoField:
+		return fmt.Sprintf("field %v %v", v.qRegName(), typeKey(v.typ.Type()))
+	}
+	panic(fmt.Sprintf("unknown symbol %T", sym))
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"strings"
+)
+
+// GoProxy is a generated Go type implementing an exported interface by
+// calling the methods of a Go+ object.
+type GoProxy struct {
+	GoObject
+	named *types.Named
+}
+
+func (p *GoProxy) qRegName() string {
+	return "New" + p.id.Name + "Proxy"
+}
+
+func (p *GoProxy) qExecName() string {
+	return "exec" + p.qRegName()
+}
+
+func (p *GoProxy) typeName() string {
+	return "proxy" + p.id.Name
+}
+
+func (p *GoProxy) newName() string {
+	return "newProxy" + p.id.Name
+}
+
+func (p *GoProxy) methods() ([]*types.Func, error) {
+	iface := p.named.Underlying().(*types.Interface)
+	if !iface.IsMethodSet() {
+		return nil, fmt.Errorf("interface %v is a type constraint", p.id)
+	}
+	var methods []*types.Func
+	mset := types.NewMethodSet(p.named)
+	for i := 0; i < mset.Len(); i++ {
+		fn := mset.At(i).Obj().(*types.Func)
+		if !fn.Exported() {
+			return nil, fmt.Errorf("interface %v has unexported method %v", p.id, fn.Name())
+		}
+		if err := checkAccess(fn.Type()); err != nil {
+			return nil, fmt.Errorf("method %v: %v", fn.Name(), err)
+		}
+		methods = append(methods, fn)
+	}
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("interface %v has no methods", p.id)
+	}
+	return methods, nil
+}
+
+func (p *GoProxy) ExportRegister(f *File) (string, error) {
+	return fmt.Sprintf("I.Func(%q, %v, %v)", p.qRegName(), p.newName(), p.qExecName()), nil
+}
+
+// ExportDecl declares the proxy of the interface, a struct of the funcs
+// converted from the methods of a Go+ object, for io.Reader:
+//
+//	type proxyReader struct {
+//		fnRead func(p []byte) (n int, err error)
+//	}
+//
+//	func (p *proxyReader) Read(a0 []byte) (int, error) {
+//		return p.fnRead(a0)
+//	}
+func (p *GoProxy) ExportDecl(f *File) (string, error) {
+	methods, err := p.methods()
+	if err != nil {
+		return "", err
+	}
+	it := f.TypeString(p.named)
+	var fields, funcs, inits []string
+	for _, m := range methods {
+		sig := m.Type().(*types.Signature)
+		fn := types.NewSignature(nil, sig.Params(), sig.Results(), sig.Variadic())
+		fields = append(fields, fmt.Sprintf("\tfn%v %v", m.Name(), f.TypeString(fn)))
+		// the methods are converted from obj, the argument 0, errors name
+		// the method.
+		inits = append(inits, fmt.Sprintf("\t\tfn%v: %v(qmethod(obj, %q), %q, 0),", m.Name(), f.funcAdapter(fn), m.Name(), p.qRegName()+": "+m.Name()))
+
+		var params, args, results []string
+		for i := 0; i < sig.Params().Len(); i++ {
+			pt := sig.Params().At(i).Type()
+			if sig.Variadic() && i == sig.Params().Len()-1 {
+				params = append(params, fmt.Sprintf("a%v ...%v", i, f.TypeString(pt.(*types.Slice).Elem())))
+				args = append(args, fmt.Sprintf("a%v...", i))
+			} else {
+				params = append(params, fmt.Sprintf("a%v %v", i, f.TypeString(pt)))
+				args = append(args, fmt.Sprintf("a%v", i))
+			}
+		}
+		for i := 0; i < sig.Results().Len(); i++ {
+			results = append(results, f.TypeString(sig.Results().At(i).Type()))
+		}
+		decl := fmt.Sprintf("func (p *%v) %v(%v) ", p.typeName(), m.Name(), strings.Join(params, ", "))
+		if len(results) == 1 {
+			decl += results[0] + " "
+		} else if len(results) > 1 {
+			decl += "(" + strings.Join(results, ", ") + ") "
+		}
+		decl += "{\n\t"
+		if len(results) > 0 {
+			decl += "return "
+		}
+		decl += fmt.Sprintf("p.fn%v(%v)\n}", m.Name(), strings.Join(args, ", "))
+		funcs = append(funcs, decl)
+	}
+	f.runtime("qmethod")
+
+	var decl string
+	decl += fmt.Sprintf("// %v implements %v by calling the methods of a Go+ object.\n", p.typeName(), it)
+	decl += fmt.Sprintf("type %v struct {\n%v\n}\n\n", p.typeName(), strings.Join(fields, "\n"))
+	decl += strings.Join(funcs, "\n\n") + "\n\n"
+	decl += fmt.Sprintf("func %v(obj interface{}) %v {\n", p.newName(), it)
+	decl += fmt.Sprintf("\treturn &%v{\n%v\n\t}\n}\n\n", p.typeName(), strings.Join(inits, "\n"))
+	decl += fmt.Sprintf("// func %v(obj interface{}) %v\n", p.qRegName(), it)
+	decl += fmt.Sprintf("func %v(_ int, p *%v.Context) {\n", p.qExecName(), f.Import(qlang_lib, qlang_def))
+	decl += "\targs := p.GetArgs(1)\n"
+	decl += fmt.Sprintf("\tret := %v(args[0])\n", p.newName())
+	decl += "\tp.Ret(1, ret)\n"
+	decl += "}"
+	return decl, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+// runtimes are the declarations of the generated runtime support, shared by
+// every helper of a file.
+var runtimes = map[string]string{
+	"qclosure": `// qclosure is a Go+ function value.
+type qclosure interface {
+	Call(args ...interface{}) []interface{}
+}`,
+	"qmethod": `// qmethod returns the method name of the Go+ object obj, a map of
+// closures or a Go value.
+func qmethod(obj interface{}, name string) interface{} {
+	if m, ok := obj.(map[string]interface{}); ok {
+		if fn, ok := m[name]; ok {
+			return fn
+		}
+	} else if fn := reflect.ValueOf(obj).MethodByName(name); fn.IsValid() {
+		return fn.Interface()
+	}
+	panic(fmt.Errorf("%T has no method %v", obj, name))
+}`,
+	"qargError": `// qargError returns the error of passing v as the argument i of fn.
+func qargError(v interface{}, fn string, i int, typ string) error {
+	return fmt.Errorf("%v: cannot use %T as %v in argument %v", fn, v, typ, i)
+}`,
+	"qresultError": `// qresultError returns the error of the Go+ closure passed as fn returning
+// ret instead of n results.
+func qresultError(ret []interface{}, fn string, n int) error {
+	return fmt.Errorf("%v: closure returns %v results, want %v", fn, len(ret), n)
+}`,
+	"qconv": `// qconv converts the argument v of fn to typ, accepting nil for nillable
+// types and numeric values which convert to typ without loss.
+func qconv(v interface{}, fn string, i int, typ reflect.Type) interface{} {
+	if v == nil {
+		switch typ.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Chan, reflect.Func, reflect.Interface, reflect.UnsafePointer:
+			return reflect.Zero(typ).Interface()
+		}
+		panic(qargError(v, fn, i, typ.String()))
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Type() == typ {
+		return v
+	}
+	if !rv.Type().ConvertibleTo(typ) {
+		panic(qargError(v, fn, i, typ.String()))
+	}
+	if typ.Kind() == reflect.Interface {
+		return rv.Convert(typ).Interface()
+	} else if qnumeric(rv.Kind()) && qnumeric(typ.Kind()) {
+		if r := rv.Convert(typ); r.Convert(rv.Type()).Interface() == v {
+			return r.Interface()
+		}
+	} else if rv.Kind() == typ.Kind() {
+		return rv.Convert(typ).Interface()
+	}
+	panic(qargError(v, fn, i, typ.String()))
+}
+
+func qnumeric(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Complex128
+}`,
+	"qbigInt": `// qbigInt returns the untyped integer constant of the decimal s.
+func qbigInt(s string) *big.Int {
+	v, _ := new(big.Int).SetString(s, 10)
+	return v
+}`,
+	"qbigRat": `// qbigRat returns the untyped float constant of the fraction s.
+func qbigRat(s string) *big.Rat {
+	v, _ := new(big.Rat).SetString(s)
+	return v
+}`,
+	"qcall": `// qcall calls the Go func fn of the Go+ function name by reflection,
+// converting args, the arguments from index base, to the parameter types of
+// fn.
+func qcall(fn reflect.Value, name string, base int, args []interface{}) []interface{} {
+	typ := fn.Type()
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		var t reflect.Type
+		if typ.IsVariadic() && i >= typ.NumIn()-1 {
+			t = typ.In(typ.NumIn() - 1).Elem()
+		} else {
+			t = typ.In(i)
+		}
+		if in[i] = reflect.ValueOf(qconv(arg, name, base+i, t)); !in[i].IsValid() {
+			in[i] = reflect.Zero(t)
+		}
+	}
+	out := fn.Call(in)
+	ret := make([]interface{}, len(out))
+	for i, v := range out {
+		ret[i] = v.Interface()
+	}
+	return ret
+}`,
+}
+
+// runtimeImports are the packages used by the runtime declarations.
+var runtimeImports = map[string][]string{
+	"qmethod":      {"fmt", "reflect"},
+	"qargError":    {"fmt"},
+	"qresultError": {"fmt"},
+	"qconv":        {"reflect"},
+	"qcall":        {"reflect"},
+	"qbigInt":      {"math/big"},
+	"qbigRat":      {"math/big"},
+}
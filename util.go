@@ -1,13 +1,12 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"go/ast"
 	"go/types"
 	"io"
 	"os"
-	"regexp"
-	"strconv"
 	"strings"
 )
 
@@ -22,16 +21,106 @@ func isSkipPkg(pkg string) bool {
 	return false
 }
 
-func checkConstType(value string) KeyType {
-	_, err := strconv.ParseInt(value, 10, 32)
-	if err != nil {
-		if value[0] == '-' {
-			return ConstInt64
-		} else {
-			return ConstUnit64
+// errTypeParam is reported by checkAccess for the type parameters of generic
+// functions and types.
+var errTypeParam = errors.New("type parameter")
+
+// checkAccess reports an error if typ, or a type it is composed of, cannot
+// be named outside its package: unexported types, types of internal or vendor
+// packages, local types and struct or interface literals with unexported
+// members.
+func checkAccess(typ types.Type) error {
+	return checkAccessType(typ, make(map[types.Type]bool))
+}
+
+func checkAccessType(typ types.Type, visited map[types.Type]bool) error {
+	if visited[typ] {
+		return nil
+	}
+	visited[typ] = true
+	switch t := typ.(type) {
+	case *types.Basic:
+		if t.Kind() == types.Invalid {
+			return fmt.Errorf("invalid type")
 		}
+	case *types.Named:
+		if err := checkAccessName(t, t.Obj()); err != nil {
+			return err
+		}
+		for i := 0; i < t.TypeArgs().Len(); i++ {
+			if err := checkAccessType(t.TypeArgs().At(i), visited); err != nil {
+				return err
+			}
+		}
+	case *types.Pointer:
+		return checkAccessType(t.Elem(), visited)
+	case *types.Slice:
+		return checkAccessType(t.Elem(), visited)
+	case *types.Array:
+		return checkAccessType(t.Elem(), visited)
+	case *types.Chan:
+		return checkAccessType(t.Elem(), visited)
+	case *types.Map:
+		if err := checkAccessType(t.Key(), visited); err != nil {
+			return err
+		}
+		return checkAccessType(t.Elem(), visited)
+	case *types.Signature:
+		for i := 0; i < t.Params().Len(); i++ {
+			if err := checkAccessType(t.Params().At(i).Type(), visited); err != nil {
+				return fmt.Errorf("param %v: %w", i, err)
+			}
+		}
+		for i := 0; i < t.Results().Len(); i++ {
+			if err := checkAccessType(t.Results().At(i).Type(), visited); err != nil {
+				return fmt.Errorf("result %v: %w", i, err)
+			}
+		}
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			f := t.Field(i)
+			if !f.Exported() {
+				return fmt.Errorf("struct has unexported field %v", f.Name())
+			}
+			if err := checkAccessType(f.Type(), visited); err != nil {
+				return fmt.Errorf("field %v: %w", f.Name(), err)
+			}
+		}
+	case *types.Interface:
+		for i := 0; i < t.NumMethods(); i++ {
+			m := t.Method(i)
+			if !m.Exported() {
+				return fmt.Errorf("interface has unexported method %v", m.Name())
+			}
+			if err := checkAccessType(m.Type(), visited); err != nil {
+				return fmt.Errorf("method %v: %w", m.Name(), err)
+			}
+		}
+	case interface{ Constraint() types.Type }:
+		return fmt.Errorf("%w %v", errTypeParam, typ)
+	case interface{ Obj() *types.TypeName }:
+		// type alias, named as is by the generated code
+		return checkAccessName(typ, t.Obj())
+	default:
+		return fmt.Errorf("unsupported type %v", typ)
+	}
+	return nil
+}
+
+func checkAccessName(typ types.Type, obj *types.TypeName) error {
+	if obj.Pkg() == nil {
+		return nil
+	}
+	if !obj.Exported() {
+		return fmt.Errorf("type %v is unexported", typ)
+	}
+	if isSkipPkg(obj.Pkg().Path()) {
+		return fmt.Errorf("type %v is in internal package %v", typ, obj.Pkg().Path())
+	}
+	if obj.Parent() != nil && obj.Parent() != obj.Pkg().Scope() {
+		return fmt.Errorf("type %v is local", typ)
 	}
-	return Normal
+	return nil
 }
 
 func checkStructHasUnexportField(decl *ast.GenDecl) bool {
@@ -54,30 +143,16 @@ func checkStructHasUnexportField(decl *ast.GenDecl) bool {
 }
 
 func simpleObjInfo(obj types.Object) string {
-	s := obj.String()
-	pkg := obj.Pkg()
-	if pkg != nil {
-		s = strings.Replace(s, pkg.Path(), pkg.Name(), -1)
-		s = simpleType(s)
-		if pkg.Name() == "main" {
-			s = strings.Replace(s, "main.", "", -1)
-		}
+	s := types.ObjectString(obj, pkgName)
+	if pkg := obj.Pkg(); pkg != nil && pkg.Name() == "main" {
+		s = strings.Replace(s, "main.", "", -1)
 	}
 	return s
 }
 
-func simpleType(src string) string {
-	re, _ := regexp.Compile("[\\w\\./]+")
-	return re.ReplaceAllStringFunc(src, func(s string) string {
-		r := s
-		if i := strings.LastIndex(s, "/"); i != -1 {
-			r = s[i+1:]
-		}
-		if strings.Count(r, ".") > 1 {
-			r = r[strings.Index(r, ".")+1:]
-		}
-		return r
-	})
+// pkgName is a types.Qualifier qualifying by package name.
+func pkgName(pkg *types.Package) string {
+	return pkg.Name()
 }
 
 func CopyFile(source string, dest string) (err error) {